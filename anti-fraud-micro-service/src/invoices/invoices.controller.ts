@@ -1,9 +1,33 @@
 import { Controller, Get, Inject, OnModuleInit, Param, Query } from '@nestjs/common';
 import { FindAllInvoiceDto } from './dto/find-all-invoice.dto';
 import { InvoicesService } from './invoices.service';
-import { ClientKafka, MessagePattern, Payload } from '@nestjs/microservices';
+import {
+  ClientKafka,
+  Ctx,
+  KafkaContext,
+  MessagePattern,
+  Payload,
+} from '@nestjs/microservices';
 import { ProcessInvoiceFraudDto } from './dto/process-invoice-fraud.dto'; // Assuming this DTO exists or will be created
 
+// W3C trace context headers copied from each pending transaction to its
+// result, so the gateway sees the round trip as a single trace
+const TRACE_CONTEXT_HEADERS = ['traceparent', 'tracestate'];
+
+function traceContextHeaders(context: KafkaContext): Record<string, string> {
+  const headers = context.getMessage().headers ?? {};
+  const traceHeaders: Record<string, string> = {};
+
+  for (const name of TRACE_CONTEXT_HEADERS) {
+    const value = headers[name];
+    if (value !== undefined && value !== null) {
+      traceHeaders[name] = value.toString();
+    }
+  }
+
+  return traceHeaders;
+}
+
 @Controller('invoices')
 export class InvoicesController implements OnModuleInit {
   // Inject Kafka client for producing results later
@@ -23,14 +47,16 @@ export class InvoicesController implements OnModuleInit {
   async handlePendingTransaction(
     @Payload()
     message: ProcessInvoiceFraudDto,
+    @Ctx() context: KafkaContext,
   ) {
+    const headers = traceContextHeaders(context);
     console.log(`Received pending transaction: ${JSON.stringify(message)}`);
     // TODO: Validate message payload with a DTO
 
     const processDto: ProcessInvoiceFraudDto = {
       invoice_id: message.invoice_id,
       account_id: message.account_id,
-      amount: message.amount,
+      amount: Number(message.amount), // Gateway sends amounts as decimal strings (e.g. "100.50")
     };
     try {
       const result = await this.invoicesService.processFraudCheck(processDto); // This method needs to be implemented in InvoicesService
@@ -40,8 +66,11 @@ export class InvoicesController implements OnModuleInit {
 
       // Produce result to 'transactions_result' topic
       this.kafkaClient.emit('transactions_result', {
-        invoice_id: result.invoiceId,
-        status: result.status, // 'approved' or 'rejected'
+        value: {
+          invoice_id: result.invoiceId,
+          status: result.status, // 'approved' or 'rejected'
+        },
+        headers,
       });
     } catch (error) {
       console.error(
@@ -51,9 +80,12 @@ export class InvoicesController implements OnModuleInit {
       // Optionally produce an error message to Kafka or handle differently
       // Ensure message.invoice_id exists and is correct case from original message
       this.kafkaClient.emit('transactions_result', {
-        invoice_id: message.invoice_id, // Keep snake_case for Kafka message consistency
-        status: 'pending',
-        error: error.message,
+        value: {
+          invoice_id: message.invoice_id, // Keep snake_case for Kafka message consistency
+          status: 'pending',
+          error: error.message,
+        },
+        headers,
       });
     }
   }
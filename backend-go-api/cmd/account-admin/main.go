@@ -0,0 +1,80 @@
+// Command account-admin suspends, reactivates or closes a merchant account:
+//
+//	account-admin suspend <account_id>
+//	account-admin reactivate <account_id>
+//	account-admin close <account_id>
+//
+// Suspension is an operator decision, so it is not exposed to merchants: a
+// suspended account cannot authenticate to lift it.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/repository"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: account-admin suspend|reactivate|close <account_id>")
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	action, accountID := flag.Arg(0), flag.Arg(1)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	connStr := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_PORT"),
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_NAME"),
+		os.Getenv("DB_SSLMODE"),
+	)
+
+	dbConn, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatal("Error connecting to database: ", err)
+	}
+	defer dbConn.Close()
+
+	accountService := service.NewAccountService(
+		repository.NewAccountRepository(dbConn),
+		repository.NewLedgerRepository(dbConn),
+		repository.NewUnitOfWork(dbConn),
+	)
+
+	var account *dto.AccountResponse
+	switch action {
+	case "suspend":
+		account, err = accountService.SuspendAccount(ctx, accountID)
+	case "reactivate":
+		account, err = accountService.ReactivateAccount(ctx, accountID)
+	case "close":
+		account, err = accountService.CloseAccountByID(ctx, accountID)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Error running %s on account %s: %v", action, accountID, err)
+	}
+
+	log.Printf("Account %s is now %s", account.ID, account.Status)
+}
@@ -3,12 +3,20 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/metrics"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/repository"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/tracing"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/web/server"
 	// "github.com/joho/godotenv" // Commented out: Env vars provided by Docker Compose
 	_ "github.com/lib/pq"
@@ -30,12 +38,18 @@ func main() {
 		os.Getenv("DB_SSLMODE"),
 	)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.NewConfig())
+	if err != nil {
+		log.Fatal("Error setting up tracing: ", err)
+	}
+
 	dbConn, err := sql.Open("postgres", connStr)
 	if err != nil {
 		log.Fatal("Error connecting to database: ", err)
 	}
 
 	defer dbConn.Close()
+	metrics.RegisterDBStats(dbConn, os.Getenv("DB_NAME"))
 
 	// Innitialize Kafka
 	baseKafkaConfig := service.NewKafkaConfig()
@@ -45,27 +59,133 @@ func main() {
 	kafkaProducer := service.NewKafkaProducer(producerConfig)
 	defer kafkaProducer.Close()
 
+	unitOfWork := repository.NewUnitOfWork(dbConn)
 	accountRepository := repository.NewAccountRepository(dbConn)
-	accountService := service.NewAccountService(accountRepository)
+	ledgerRepository := repository.NewLedgerRepository(dbConn)
+	accountService := service.NewAccountService(accountRepository, ledgerRepository, unitOfWork)
 	invoiceRepository := repository.NewInvoiceRepository(dbConn)
-	invoiceService := service.NewInvoiceService(invoiceRepository, *accountService, kafkaProducer)
+	invoiceConfig, err := service.NewInvoiceServiceConfig()
+	if err != nil {
+		log.Fatal("Error loading invoice configuration: ", err)
+	}
+	cardConfig, err := service.NewCardServiceConfig()
+	if err != nil {
+		log.Fatal("Error loading card vault configuration: ", err)
+	}
+	cardService, err := service.NewCardService(repository.NewCardTokenRepository(dbConn), cardConfig)
+	if err != nil {
+		log.Fatal("Error initializing card vault: ", err)
+	}
+	webhookService := service.NewWebhookService(repository.NewWebhookRepository(dbConn), service.NewWebhookServiceConfig())
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(dbConn), unitOfWork, service.NewAPIKeyServiceConfig())
+	invoiceService := service.NewInvoiceService(invoiceRepository, cardService, unitOfWork, service.NewSimulatedPaymentProcessor(), invoiceConfig)
+	rateLimitConfig, err := service.NewRateLimiterConfig()
+	if err != nil {
+		log.Fatal("Error loading rate limit configuration: ", err)
+	}
+	var rateLimitStore domain.RateLimitStore = service.NewMemoryRateLimitStore()
+	if rateLimitConfig.Store == service.RateLimitStorePostgres {
+		rateLimitStore = repository.NewRateLimitRepository(dbConn)
+	}
+	rateLimiter := service.NewRateLimiter(rateLimitStore, rateLimitConfig)
+
+	// The root context is cancelled on SIGINT/SIGTERM to start the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+
+	// Start outbox relay go routine, publishing committed events to Kafka
+	outboxRelay := service.NewOutboxRelay(unitOfWork, kafkaProducer, service.NewOutboxRelayConfig())
+	runWorker(&workers, "relaying outbox messages", func() error {
+		return outboxRelay.Run(ctx)
+	})
+
+	// Start authorization expirer go routine, expiring uncaptured authorizations
+	authorizationExpirer := service.NewAuthorizationExpirer(invoiceService, invoiceConfig.AuthorizationExpiryInterval)
+	runWorker(&workers, "expiring authorizations", func() error {
+		return authorizationExpirer.Run(ctx)
+	})
+
+	// Start webhook dispatcher go routine, delivering merchant webhooks
+	webhookDispatcher := service.NewWebhookDispatcher(unitOfWork, service.NewWebhookDispatcherConfig())
+	runWorker(&workers, "dispatching webhooks", func() error {
+		return webhookDispatcher.Run(ctx)
+	})
 
 	// Config Kafka consumer
 	consumerTopic := os.Getenv("KAFKA_TRANSACTIONS_RESULT_TOPIC")
 	consumerConfig := baseKafkaConfig.WithTopic(consumerTopic)
 	groupID := os.Getenv("KAFKA_CONSUMER_GROUP_ID")
-	kafkaConsumer := service.NewKafkaConsumer(consumerConfig, groupID, invoiceService)
+	deadLetterConfig := baseKafkaConfig.WithTopic(service.DeadLetterTopic(consumerTopic))
+	deadLetterProducer := service.NewKafkaDeadLetterProducer(deadLetterConfig, groupID)
+	defer deadLetterProducer.Close()
+	kafkaConsumer := service.NewKafkaConsumer(consumerConfig, groupID, invoiceService, deadLetterProducer, service.NewKafkaConsumerConfig())
 	defer kafkaConsumer.Close()
+	metrics.RegisterConsumerLag(consumerTopic, groupID, kafkaConsumer.Lag)
 	// Start Kafka consumer go routine
+	runWorker(&workers, "consuming kafka messages", func() error {
+		return kafkaConsumer.Consume(ctx)
+	})
+
+	healthService := service.NewHealthService(
+		repository.NewSchemaRepository(dbConn),
+		baseKafkaConfig.Brokers,
+		kafkaConsumer,
+		service.NewHealthServiceConfig(repository.SchemaVersion),
+	)
+
+	server := server.NewServer(accountService, invoiceService, cardService, webhookService, apiKeyService, healthService, rateLimiter, os.Getenv("HTTP_PORT"))
+	serverErr := make(chan error, 1)
 	go func() {
-		if err := kafkaConsumer.Consume(context.Background()); err != nil {
-			log.Printf("Error consuming kafka messages: %v", err)
+		serverErr <- server.Start()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Error starting server: ", err)
 		}
+	case <-ctx.Done():
+		log.Print("Shutting down...")
+	}
+	stop()
+
+	// Everything below shares one deadline; the deferred Close calls then
+	// flush the Kafka writers and release the connections
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), service.NewShutdownConfig().Timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
 	}()
 
-	server := server.NewServer(accountService, invoiceService, os.Getenv("HTTP_PORT"))
-	err = server.Start()
-	if err != nil {
-		log.Fatal("Error starting server: ", err)
+	select {
+	case <-workersDone:
+		log.Print("Shutdown complete")
+	case <-shutdownCtx.Done():
+		log.Print("Shutdown deadline exceeded, exiting with workers still running")
 	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error flushing traces: %v", err)
+	}
+}
+
+// runWorker starts fn in a go routine tracked by workers. Cancellation on
+// shutdown is not reported as an error.
+func runWorker(workers *sync.WaitGroup, description string, fn func() error) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		if err := fn(); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Error %s: %v", description, err)
+		}
+	}()
 }
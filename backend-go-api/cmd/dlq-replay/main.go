@@ -0,0 +1,45 @@
+// Command dlq-replay moves transaction results parked in the dead-letter
+// topic back to KAFKA_TRANSACTIONS_RESULT_TOPIC so the gateway processes
+// them again.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+)
+
+func main() {
+	limit := flag.Int("limit", 0, "maximum number of messages to replay (0 replays all)")
+	idle := flag.Duration("idle", 10*time.Second, "stop after no message arrives for this long")
+	groupID := flag.String("group", "gateway-dlq-replay", "consumer group used to track replayed messages")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	baseKafkaConfig := service.NewKafkaConfig()
+	targetTopic := os.Getenv("KAFKA_TRANSACTIONS_RESULT_TOPIC")
+	if targetTopic == "" {
+		log.Fatal("KAFKA_TRANSACTIONS_RESULT_TOPIC is required")
+	}
+
+	replayer := service.NewDeadLetterReplayer(
+		baseKafkaConfig.WithTopic(service.DeadLetterTopic(targetTopic)),
+		baseKafkaConfig.WithTopic(targetTopic),
+		*groupID,
+	)
+	defer replayer.Close()
+
+	replayed, err := replayer.Replay(ctx, *idle, *limit)
+	if err != nil {
+		log.Fatalf("Error replaying dead-letter messages after %d replayed: %v", replayed, err)
+	}
+
+	log.Printf("Replayed %d messages into %s", replayed, targetTopic)
+}
@@ -3,21 +3,43 @@ package domain
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"net/mail"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountStatus string
+
+const (
+	AccountStatusActive    AccountStatus = "active"
+	AccountStatusSuspended AccountStatus = "suspended"
+	AccountStatusClosed    AccountStatus = "closed"
+)
+
+const (
+	MaxAccountNameLength  = 255
+	MaxAccountEmailLength = 255
+)
+
+// Account is a merchant. APIKey is only set on accounts returned by
+// NewAccount: keys are stored hashed, so loaded accounts leave it empty.
+//
+// Only active accounts can authenticate. A suspended account is kept as is
+// until it is reactivated, while a closed account is anonymized for good.
 type Account struct {
 	ID        string
 	Name      string
 	Email     string
 	APIKey    string
-	Balance   float64
+	Status    AccountStatus
+	balances  map[string]Money
 	mu        sync.RWMutex
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	ClosedAt  *time.Time
 }
 
 func generateAPIKey() string {
@@ -31,8 +53,8 @@ func NewAccount(name, email string) *Account {
 		ID:        uuid.New().String(),
 		Name:      name,
 		Email:     email,
-		Balance:   0,
 		APIKey:    generateAPIKey(),
+		Status:    AccountStatusActive,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -40,9 +62,131 @@ func NewAccount(name, email string) *Account {
 	return account
 }
 
-func (a *Account) AddBalance(amount float64) {
+// ValidateAccountName trims the name and checks it is not empty and fits
+// the accounts table.
+func ValidateAccountName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxAccountNameLength {
+		return "", ErrInvalidAccountName
+	}
+
+	return name, nil
+}
+
+// ValidateEmail trims the email and checks it is a bare address such as
+// user@example.com, without a display name.
+func ValidateEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if len(email) > MaxAccountEmailLength {
+		return "", ErrInvalidEmail
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	return email, nil
+}
+
+// Update changes the name and email of the account. Nil values are left
+// unchanged.
+func (a *Account) Update(name, email *string) error {
+	if a.Status == AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	if name != nil {
+		validName, err := ValidateAccountName(*name)
+		if err != nil {
+			return err
+		}
+		a.Name = validName
+	}
+
+	if email != nil {
+		validEmail, err := ValidateEmail(*email)
+		if err != nil {
+			return err
+		}
+		a.Email = validEmail
+	}
+
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// Suspend blocks an active account from authenticating.
+func (a *Account) Suspend() error {
+	if a.Status != AccountStatusActive {
+		return ErrInvalidAccountStatus
+	}
+
+	a.Status = AccountStatusSuspended
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// Reactivate lets a suspended account authenticate again.
+func (a *Account) Reactivate() error {
+	if a.Status != AccountStatusSuspended {
+		return ErrInvalidAccountStatus
+	}
+
+	a.Status = AccountStatusActive
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// Close closes the account for good and anonymizes its personal data. The
+// email becomes a unique placeholder under the reserved .invalid domain.
+// Invoices and ledger entries are not personal data of the merchant and are
+// kept for accounting.
+func (a *Account) Close(now time.Time) error {
+	if a.Status == AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	a.Name = ""
+	a.Email = a.ID + "@closed.invalid"
+	a.Status = AccountStatusClosed
+	a.UpdatedAt = now
+	a.ClosedAt = &now
+	return nil
+}
+
+// Balance returns the balance held in currency, zero if there is none.
+func (a *Account) Balance(currency string) Money {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if balance, ok := a.balances[currency]; ok {
+		return balance
+	}
+
+	return NewMoney(0, currency)
+}
+
+// Balances returns every currency balance of the account sorted by currency.
+func (a *Account) Balances() []Money {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	balances := make([]Money, 0, len(a.balances))
+	for _, currency := range sortedCurrencies(a.balances) {
+		balances = append(balances, a.balances[currency])
+	}
+
+	return balances
+}
+
+func (a *Account) SetBalance(balance Money) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.Balance += amount
-	a.UpdatedAt = time.Now()
+
+	if a.balances == nil {
+		a.balances = make(map[string]Money)
+	}
+
+	a.balances[balance.Currency] = balance
 }
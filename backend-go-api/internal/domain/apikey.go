@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxAPIKeyLabelLength = 255
+
+	// DefaultAPIKeyLabel names the key an account is created with.
+	DefaultAPIKeyLabel = "default"
+
+	// apiKeyPrefixLength is how much of a key is shown after creation so
+	// merchants can tell their keys apart.
+	apiKeyPrefixLength = 8
+
+	// LastUsedResolution is how stale the last use of a key may get.
+	// Skipping closer updates keeps authentication from writing on every
+	// request.
+	LastUsedResolution = time.Minute
+)
+
+// APIKey authenticates requests of an account. An account may hold several
+// keys so they can be rotated: a revoked key keeps working until ExpiresAt,
+// giving clients a grace period to switch to the new one.
+//
+// Only the digest and the visible prefix of a key are stored. Key holds the
+// plaintext right after creation and is empty on keys loaded from storage.
+// AccountStatus is only loaded by APIKeyRepository.FindByKey, for
+// authentication.
+type APIKey struct {
+	ID            string
+	AccountID     string
+	AccountStatus AccountStatus
+	Key           string
+	Prefix        string
+	Hash          []byte
+	Label         string
+	CreatedAt     time.Time
+	LastUsedAt    *time.Time
+	RevokedAt     *time.Time
+	ExpiresAt     *time.Time
+}
+
+func NewAPIKey(accountID, label string) (*APIKey, error) {
+	label = strings.TrimSpace(label)
+	if len(label) > MaxAPIKeyLabelLength {
+		return nil, ErrInvalidAPIKeyLabel
+	}
+
+	key := generateAPIKey()
+
+	return &APIKey{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Key:       key,
+		Prefix:    APIKeyPrefix(key),
+		Hash:      HashAPIKey(key),
+		Label:     label,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// InitialAPIKey wraps the key generated by NewAccount so it can be stored
+// as the first key of the account.
+func InitialAPIKey(account *Account) *APIKey {
+	return &APIKey{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Key:       account.APIKey,
+		Prefix:    APIKeyPrefix(account.APIKey),
+		Hash:      HashAPIKey(account.APIKey),
+		Label:     DefaultAPIKeyLabel,
+		CreatedAt: account.CreatedAt,
+	}
+}
+
+// HashAPIKey returns the SHA-256 digest keys are stored and looked up by.
+// Keys are random 128-bit values, so they need no salt or slow hash.
+func HashAPIKey(key string) []byte {
+	digest := sha256.Sum256([]byte(key))
+	return digest[:]
+}
+
+// APIKeyPrefix returns the visible start of a key, which narrows lookups
+// and lets merchants tell their keys apart.
+func APIKeyPrefix(key string) string {
+	if len(key) <= apiKeyPrefixLength {
+		return key
+	}
+
+	return key[:apiKeyPrefixLength]
+}
+
+// IsActive reports whether the key still authenticates requests at now.
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// LastUsedStale reports whether the last use recorded for the key is older
+// than LastUsedResolution at now, so it should be recorded again.
+func (k *APIKey) LastUsedStale(now time.Time) bool {
+	return k.LastUsedAt == nil || k.LastUsedAt.Before(now.Add(-LastUsedResolution))
+}
+
+// Revoke stops the key from working once gracePeriod has passed. A zero
+// grace period revokes it immediately.
+func (k *APIKey) Revoke(now time.Time, gracePeriod time.Duration) error {
+	if k.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+
+	if gracePeriod < 0 {
+		return ErrInvalidGracePeriod
+	}
+
+	expiresAt := now.Add(gracePeriod)
+	k.RevokedAt = &now
+	k.ExpiresAt = &expiresAt
+
+	return nil
+}
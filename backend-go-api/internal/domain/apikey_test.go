@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIKeyLastUsedStale(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		usedAt := now.Add(-d)
+		return &usedAt
+	}
+
+	tests := []struct {
+		name       string
+		lastUsedAt *time.Time
+		want       bool
+	}{
+		{"never used", nil, true},
+		{"just used", at(0), false},
+		{"within the resolution", at(LastUsedResolution - time.Second), false},
+		{"at the resolution", at(LastUsedResolution), false},
+		{"older than the resolution", at(LastUsedResolution + time.Second), true},
+	}
+
+	for _, tt := range tests {
+		key := &APIKey{LastUsedAt: tt.lastUsedAt}
+		if got := key.LastUsedStale(now); got != tt.want {
+			t.Errorf("%s: LastUsedStale() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
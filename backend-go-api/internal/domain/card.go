@@ -0,0 +1,234 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CardBrand string
+
+const (
+	CardBrandVisa       CardBrand = "visa"
+	CardBrandMastercard CardBrand = "mastercard"
+	CardBrandAmex       CardBrand = "amex"
+	CardBrandElo        CardBrand = "elo"
+	CardBrandHipercard  CardBrand = "hipercard"
+)
+
+// Request fields reported by CardValidationError.
+const (
+	CardFieldNumber      = "card_number"
+	CardFieldCVV         = "card_cvv"
+	CardFieldExpiryMonth = "expiry_month"
+	CardFieldExpiryYear  = "expiry_year"
+	CardFieldToken       = "card_token"
+)
+
+// CardValidationError tells which card field was rejected. It matches
+// ErrInvalidCard with errors.Is.
+type CardValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *CardValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *CardValidationError) Unwrap() error {
+	return ErrInvalidCard
+}
+
+func invalidCard(field, message string) error {
+	return &CardValidationError{Field: field, Message: message}
+}
+
+// AsCardValidationError returns the CardValidationError wrapped in err, if any.
+func AsCardValidationError(err error) (*CardValidationError, bool) {
+	var validationErr *CardValidationError
+	ok := errors.As(err, &validationErr)
+	return validationErr, ok
+}
+
+type CreditCard struct {
+	Number         string
+	CVV            string
+	ExpiryMonth    int
+	ExpiryYear     int
+	CardholderName string
+	// Stored cards come from the vault. Their CVV was checked when they
+	// were tokenized and is not kept, so it is optional on charges.
+	Stored bool
+}
+
+// binRange is an inclusive range of 6-digit issuer identification numbers.
+type binRange struct {
+	from, to int
+}
+
+// Elo and Hipercard ranges overlap the Visa and Mastercard prefixes, so
+// they are checked first.
+var (
+	eloBINs = []binRange{
+		{401178, 401179}, {431274, 431274}, {438935, 438935}, {451416, 451416},
+		{457393, 457393}, {457631, 457632}, {504175, 504175}, {506699, 506778},
+		{509000, 509999}, {627780, 627780}, {636297, 636297}, {636368, 636368},
+		{650031, 650033}, {650035, 650051}, {650405, 650439}, {650485, 650538},
+		{650541, 650598}, {650700, 650718}, {650720, 650727}, {650901, 650978},
+		{651652, 651679}, {655000, 655019}, {655021, 655058},
+	}
+	hipercardBINs = []binRange{
+		{384100, 384100}, {384140, 384140}, {384160, 384160}, {606282, 606282},
+		{637095, 637095}, {637568, 637568}, {637599, 637599}, {637609, 637609},
+		{637612, 637612},
+	}
+)
+
+// NormalizeCardNumber strips the spaces and dashes people type in card
+// numbers.
+func NormalizeCardNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// DetectCardBrand identifies the brand of a normalized card number from its
+// prefix and length. It returns an empty brand when none matches.
+func DetectCardBrand(number string) CardBrand {
+	if len(number) < 6 || !isDigits(number) {
+		return ""
+	}
+
+	bin, _ := strconv.Atoi(number[:6])
+	length := len(number)
+
+	switch {
+	case inBINRanges(bin, eloBINs) && length == 16:
+		return CardBrandElo
+	case inBINRanges(bin, hipercardBINs) && (length == 16 || length == 19):
+		return CardBrandHipercard
+	case (strings.HasPrefix(number, "34") || strings.HasPrefix(number, "37")) && length == 15:
+		return CardBrandAmex
+	case isMastercardBIN(bin) && length == 16:
+		return CardBrandMastercard
+	case number[0] == '4' && (length == 13 || length == 16 || length == 19):
+		return CardBrandVisa
+	}
+
+	return ""
+}
+
+// CVVLength returns how many digits the security code of the brand has.
+func (b CardBrand) CVVLength() int {
+	if b == CardBrandAmex {
+		return 4
+	}
+
+	return 3
+}
+
+// Validate checks the card number with the Luhn algorithm, the expiry
+// against now and the CVV length for the card brand, which is returned.
+// The first failing field is reported as a CardValidationError.
+func (c CreditCard) Validate(now time.Time) (CardBrand, error) {
+	number := NormalizeCardNumber(c.Number)
+
+	if len(number) < 12 || len(number) > 19 || !isDigits(number) {
+		return "", invalidCard(CardFieldNumber, "must have between 12 and 19 digits")
+	}
+
+	if !luhnValid(number) {
+		return "", invalidCard(CardFieldNumber, "is not a valid card number")
+	}
+
+	brand := DetectCardBrand(number)
+	if brand == "" {
+		return "", invalidCard(CardFieldNumber, "card brand is not supported")
+	}
+
+	if c.ExpiryMonth < 1 || c.ExpiryMonth > 12 {
+		return "", invalidCard(CardFieldExpiryMonth, "must be between 1 and 12")
+	}
+
+	year := c.ExpiryYear
+	if year >= 0 && year < 100 {
+		year += 2000
+	}
+
+	// Cards are valid through the last day of the expiry month
+	expiresAt := time.Date(year, time.Month(c.ExpiryMonth)+1, 1, 0, 0, 0, 0, now.Location())
+	if !now.Before(expiresAt) {
+		return "", invalidCard(CardFieldExpiryYear, "card has expired")
+	}
+
+	if c.Stored && c.CVV == "" {
+		return brand, nil
+	}
+
+	if len(c.CVV) != brand.CVVLength() || !isDigits(c.CVV) {
+		return "", invalidCard(CardFieldCVV, "must have "+strconv.Itoa(brand.CVVLength())+" digits for "+string(brand)+" cards")
+	}
+
+	return brand, nil
+}
+
+// LastDigits returns the last four digits of the card number.
+func (c CreditCard) LastDigits() string {
+	number := NormalizeCardNumber(c.Number)
+	if len(number) < 4 {
+		return number
+	}
+
+	return number[len(number)-4:]
+}
+
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func isMastercardBIN(bin int) bool {
+	prefix2 := bin / 10000
+	prefix4 := bin / 100
+
+	return (prefix2 >= 51 && prefix2 <= 55) || (prefix4 >= 2221 && prefix4 <= 2720)
+}
+
+func inBINRanges(bin int, ranges []binRange) bool {
+	for _, r := range ranges {
+		if bin >= r.from && bin <= r.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withCheckDigit completes number with its Luhn check digit, padding it to
+// length first.
+func withCheckDigit(prefix string, length int) string {
+	number := prefix + strings.Repeat("0", length-len(prefix)-1)
+
+	for digit := 0; digit <= 9; digit++ {
+		if candidate := number + strconv.Itoa(digit); luhnValid(candidate) {
+			return candidate
+		}
+	}
+
+	panic("no check digit for " + number)
+}
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4111111111111112", false},
+		{"5555555555554444", true},
+		{"378282246310005", true},
+		{"378282246310006", false},
+		{"79927398713", true},
+		{"79927398710", false},
+		{"0000000000000000", true},
+	}
+
+	for _, tt := range tests {
+		if got := luhnValid(tt.number); got != tt.want {
+			t.Errorf("luhnValid(%s) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestDetectCardBrand(t *testing.T) {
+	tests := []struct {
+		name   string
+		number string
+		want   CardBrand
+	}{
+		{"visa 16", "4111111111111111", CardBrandVisa},
+		{"visa 13", withCheckDigit("4", 13), CardBrandVisa},
+		{"visa 19", withCheckDigit("4", 19), CardBrandVisa},
+		{"visa 15 digits", withCheckDigit("4", 15), ""},
+		{"mastercard 51", withCheckDigit("51", 16), CardBrandMastercard},
+		{"mastercard 55", "5555555555554444", CardBrandMastercard},
+		{"mastercard 2221", withCheckDigit("2221", 16), CardBrandMastercard},
+		{"mastercard 2720", withCheckDigit("2720", 16), CardBrandMastercard},
+		{"not mastercard 2220", withCheckDigit("2220", 16), ""},
+		{"not mastercard 2721", withCheckDigit("2721", 16), ""},
+		{"not mastercard 56", withCheckDigit("56", 16), ""},
+		{"amex 34", withCheckDigit("34", 15), CardBrandAmex},
+		{"amex 37", "378282246310005", CardBrandAmex},
+		{"amex 16 digits", withCheckDigit("37", 16), ""},
+		{"elo inside visa prefix", withCheckDigit("401178", 16), CardBrandElo},
+		{"elo range start", withCheckDigit("506699", 16), CardBrandElo},
+		{"elo range end", withCheckDigit("506778", 16), CardBrandElo},
+		{"elo 636368", withCheckDigit("636368", 16), CardBrandElo},
+		{"after elo range", withCheckDigit("506779", 16), ""},
+		{"visa next to elo", withCheckDigit("401180", 16), CardBrandVisa},
+		{"hipercard 16", withCheckDigit("606282", 16), CardBrandHipercard},
+		{"hipercard 19", withCheckDigit("606282", 19), CardBrandHipercard},
+		{"hipercard 384100", withCheckDigit("384100", 16), CardBrandHipercard},
+		{"unknown prefix", withCheckDigit("9", 16), ""},
+		{"too short", "41111", ""},
+		{"not digits", "4111-1111-1111-1111", ""},
+	}
+
+	for _, tt := range tests {
+		if got := DetectCardBrand(tt.number); got != tt.want {
+			t.Errorf("%s: DetectCardBrand(%s) = %q, want %q", tt.name, tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestCreditCardValidate(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+	valid := CreditCard{Number: "4111111111111111", CVV: "123", ExpiryMonth: 12, ExpiryYear: 2030}
+	card := func(change func(*CreditCard)) CreditCard {
+		c := valid
+		change(&c)
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		card      CreditCard
+		now       time.Time
+		wantBrand CardBrand
+		wantField string
+	}{
+		{"valid", valid, now, CardBrandVisa, ""},
+		{"spaces and dashes", card(func(c *CreditCard) { c.Number = "4111 1111-1111 1111" }), now, CardBrandVisa, ""},
+		{"too short", card(func(c *CreditCard) { c.Number = "41111111111" }), now, "", CardFieldNumber},
+		{"too long", card(func(c *CreditCard) { c.Number = withCheckDigit("4", 20) }), now, "", CardFieldNumber},
+		{"letters", card(func(c *CreditCard) { c.Number = "4111a11111111111" }), now, "", CardFieldNumber},
+		{"luhn", card(func(c *CreditCard) { c.Number = "4111111111111112" }), now, "", CardFieldNumber},
+		{"unsupported brand", card(func(c *CreditCard) { c.Number = withCheckDigit("9", 16) }), now, "", CardFieldNumber},
+		{"month 0", card(func(c *CreditCard) { c.ExpiryMonth = 0 }), now, "", CardFieldExpiryMonth},
+		{"month 13", card(func(c *CreditCard) { c.ExpiryMonth = 13 }), now, "", CardFieldExpiryMonth},
+		{"current month", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 10, 2026 }), now, CardBrandVisa, ""},
+		{"last instant of current month", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 10, 2026 }),
+			time.Date(2026, time.October, 31, 23, 59, 59, 0, time.UTC), CardBrandVisa, ""},
+		{"first instant after expiry", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 10, 2026 }),
+			time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC), "", CardFieldExpiryYear},
+		{"previous month", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 9, 2026 }), now, "", CardFieldExpiryYear},
+		{"december rolls over the year", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 12, 2026 }),
+			time.Date(2026, time.December, 31, 12, 0, 0, 0, time.UTC), CardBrandVisa, ""},
+		{"two digit year", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 10, 26 }), now, CardBrandVisa, ""},
+		{"two digit year expired", card(func(c *CreditCard) { c.ExpiryMonth, c.ExpiryYear = 9, 26 }), now, "", CardFieldExpiryYear},
+		{"two digit year 0", card(func(c *CreditCard) { c.ExpiryYear = 0 }), now, "", CardFieldExpiryYear},
+		{"cvv too short", card(func(c *CreditCard) { c.CVV = "12" }), now, "", CardFieldCVV},
+		{"cvv letters", card(func(c *CreditCard) { c.CVV = "12a" }), now, "", CardFieldCVV},
+		{"missing cvv", card(func(c *CreditCard) { c.CVV = "" }), now, "", CardFieldCVV},
+		{"amex cvv 4", card(func(c *CreditCard) { c.Number, c.CVV = "378282246310005", "1234" }), now, CardBrandAmex, ""},
+		{"amex cvv 3", card(func(c *CreditCard) { c.Number, c.CVV = "378282246310005", "123" }), now, "", CardFieldCVV},
+		{"visa cvv 4", card(func(c *CreditCard) { c.CVV = "1234" }), now, "", CardFieldCVV},
+		{"stored card without cvv", card(func(c *CreditCard) { c.CVV, c.Stored = "", true }), now, CardBrandVisa, ""},
+		{"stored card with wrong cvv", card(func(c *CreditCard) { c.CVV, c.Stored = "12", true }), now, "", CardFieldCVV},
+	}
+
+	for _, tt := range tests {
+		brand, err := tt.card.Validate(tt.now)
+
+		if tt.wantField == "" {
+			if err != nil || brand != tt.wantBrand {
+				t.Errorf("%s: Validate() = %q, %v, want %q", tt.name, brand, err, tt.wantBrand)
+			}
+			continue
+		}
+
+		validationErr, ok := AsCardValidationError(err)
+		if !ok || validationErr.Field != tt.wantField {
+			t.Errorf("%s: Validate() error = %v, want an error on %s", tt.name, err, tt.wantField)
+			continue
+		}
+		if !errors.Is(err, ErrInvalidCard) {
+			t.Errorf("%s: error %v does not match ErrInvalidCard", tt.name, err)
+		}
+	}
+}
+
+func TestCreditCardLastDigits(t *testing.T) {
+	card := CreditCard{Number: "4111 1111 1111 1234"}
+	if got := card.LastDigits(); got != "1234" {
+		t.Errorf("LastDigits() = %q, want 1234", got)
+	}
+}
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	CurrencyBRL = "BRL"
+	CurrencyUSD = "USD"
+	CurrencyEUR = "EUR"
+)
+
+var supportedCurrencies = map[string]bool{
+	CurrencyBRL: true,
+	CurrencyUSD: true,
+	CurrencyEUR: true,
+}
+
+// NormalizeCurrency upper-cases an ISO-4217 code, defaulting to
+// DefaultCurrency when empty.
+func NormalizeCurrency(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency
+	}
+
+	return code
+}
+
+// SupportedCurrencies lists the accepted currency codes in alphabetical
+// order.
+func SupportedCurrencies() []string {
+	return sortedCurrencies(supportedCurrencies)
+}
+
+func ValidateCurrency(code string) error {
+	if !supportedCurrencies[code] {
+		return ErrUnsupportedCurrency
+	}
+
+	return nil
+}
+
+// ReviewThresholds holds, per currency, the amount from which invoices are
+// sent to the anti-fraud service instead of being processed immediately.
+type ReviewThresholds map[string]Money
+
+func DefaultReviewThresholds() ReviewThresholds {
+	return ReviewThresholds{
+		CurrencyBRL: NewMoney(10000*minorUnitsPerMajor, CurrencyBRL),
+		CurrencyUSD: NewMoney(10000*minorUnitsPerMajor, CurrencyUSD),
+		CurrencyEUR: NewMoney(10000*minorUnitsPerMajor, CurrencyEUR),
+	}
+}
+
+// ParseReviewThresholds reads overrides in the form "BRL:10000,USD:2000.50"
+// on top of the defaults.
+func ParseReviewThresholds(value string) (ReviewThresholds, error) {
+	thresholds := DefaultReviewThresholds()
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		code, amount, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, ErrInvalidReviewThreshold
+		}
+
+		code = NormalizeCurrency(code)
+		if err := ValidateCurrency(code); err != nil {
+			return nil, err
+		}
+
+		threshold, err := ParseMoney(amount, code)
+		if err != nil || !threshold.IsPositive() {
+			return nil, ErrInvalidReviewThreshold
+		}
+
+		thresholds[code] = threshold
+	}
+
+	return thresholds, nil
+}
+
+// RequiresReview reports whether amount must go through fraud analysis.
+// Currencies without a configured threshold are always reviewed.
+func (t ReviewThresholds) RequiresReview(amount Money) bool {
+	threshold, ok := t[amount.Currency]
+	if !ok {
+		return true
+	}
+
+	return amount.Amount >= threshold.Amount
+}
+
+func sortedCurrencies[V any](values map[string]V) []string {
+	codes := make([]string, 0, len(values))
+	for code := range values {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
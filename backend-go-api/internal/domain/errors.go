@@ -2,11 +2,71 @@ package domain
 
 import "errors"
 
+// Errors made with newInputError reject a field of the request body; they
+// all match ErrInvalidInput, so handlers answer them with 422 Unprocessable
+// Entity like the field errors of a ValidationError.
 var (
 	ErrAccountNotFound    = errors.New("account not found")
 	ErrDuplicateAPIKey    = errors.New("api key already exists")
 	ErrInvoiceNotFound    = errors.New("invoice not found")
 	ErrUnauthorizedAccess = errors.New("unauthorized access")
-	ErrInvalidAmount      = errors.New("invalid amount, must be greater than 0")
+	ErrUnauthenticated    = errors.New("request is not authenticated")
+	ErrInvalidAmount      = newInputError("invalid amount, must be greater than 0")
 	ErrInvalidStatus      = errors.New("invalid status")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrInvalidMoney       = newInputError(`invalid amount format, use a decimal string with up to 2 decimal places such as "100.50"`)
+	ErrMoneyNotString     = newInputError(`amounts must be sent as decimal strings such as "100.50" or as integer numbers of cents such as 10050`)
+	ErrAmountTooLarge     = newInputError("amount exceeds the maximum of 99999999.99")
+	ErrCurrencyMismatch   = errors.New("currency mismatch")
+
+	ErrUnsupportedCurrency    = newInputError("unsupported currency, must be one of BRL, USD, EUR")
+	ErrInvalidReviewThreshold = errors.New("invalid review threshold, use CURRENCY:AMOUNT pairs")
+
+	ErrUnbalancedLedgerTransaction = errors.New("ledger transaction debits and credits do not balance")
+	ErrInvalidCursor               = errors.New("invalid cursor")
+	ErrInvalidPageLimit            = errors.New("limit must be a positive integer")
+	ErrInvalidInvoiceFilter        = errors.New("invalid invoice filter")
+	ErrUnsupportedExportFormat     = errors.New("unsupported export format, use csv or jsonl")
+
+	ErrInvoiceNotRefundable = errors.New("invoice cannot be refunded in its current status")
+	ErrRefundExceedsAmount  = errors.New("refund exceeds the refundable amount of the invoice")
+	ErrInsufficientBalance  = errors.New("insufficient balance")
+
+	ErrAuthorizationExpired = errors.New("authorization has expired")
+	ErrCaptureExceedsAmount = errors.New("capture exceeds the authorized amount")
+
+	ErrInvalidCard             = newInputError("invalid card")
+	ErrCardTokenNotFound       = errors.New("card token not found")
+	ErrInvalidEncryptionKey    = errors.New("invalid card encryption key, must be 32 bytes encoded in base64")
+	ErrPaymentProcessorTimeout = errors.New("payment processor did not respond in time")
+
+	ErrInvalidWebhookURL       = newInputError("invalid webhook url, must be an absolute https url to a public host")
+	ErrWebhookAddressBlocked   = errors.New("webhook url resolves to an address that is not public")
+	ErrInvalidWebhookEventType = newInputError("invalid webhook event types, use invoice.created, invoice.approved or invoice.rejected")
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrWebhookDeliveryInFlight = errors.New("webhook delivery is being sent, try again once the attempt is recorded")
+
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrAPIKeyRevoked      = errors.New("api key is already revoked")
+	ErrLastAPIKey         = errors.New("cannot revoke the last api key of the account, create another one first")
+	ErrInvalidAPIKeyLabel = newInputError("invalid api key label, must be at most 255 characters")
+	ErrInvalidGracePeriod = newInputError("invalid grace period, use a non-negative duration such as 24h within the allowed maximum")
+
+	ErrInvalidRateLimit = errors.New("invalid rate limit, use <requests>/<period> such as 100/1m")
+	ErrRateLimited      = errors.New("rate limit exceeded")
+
+	ErrUnsupportedRateLimitStore = errors.New("unsupported rate limit store, must be memory or postgres")
+
+	ErrInvalidAccountName   = newInputError("invalid account name, must be between 1 and 255 characters")
+	ErrInvalidEmail         = newInputError("invalid email, use an address such as user@example.com")
+	ErrDuplicateEmail       = errors.New("email already belongs to another account")
+	ErrInvalidAccountStatus = errors.New("account cannot change to that status from its current status")
+	ErrAccountSuspended     = errors.New("account is suspended")
+	ErrAccountClosed        = errors.New("account is closed")
+
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrInvalidIdempotencyKey  = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key already used with a different request")
+	ErrIdempotencyKeyInUse    = errors.New("a request with this idempotency key is still in progress")
 )
@@ -1,15 +1,22 @@
 package events
 
+import "github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+
+// PendingTransactionEvent identifies PendingTransaction payloads in the outbox.
+const PendingTransactionEvent = "pending_transaction"
+
 type PendingTransaction struct {
-	AccountID string  `json:"account_id"`
-	InvoiceID string  `json:"invoice_id"`
-	Amount    float64 `json:"amount"`
+	AccountID string       `json:"account_id"`
+	InvoiceID string       `json:"invoice_id"`
+	Amount    domain.Money `json:"amount"`
+	Currency  string       `json:"currency"`
 }
 
-func NewPendingTransaction(accountID, invoiceID string, amount float64) *PendingTransaction {
+func NewPendingTransaction(accountID, invoiceID string, amount domain.Money) *PendingTransaction {
 	return &PendingTransaction{
 		AccountID: accountID,
 		InvoiceID: invoiceID,
 		Amount:    amount,
+		Currency:  amount.Currency,
 	}
-}
\ No newline at end of file
+}
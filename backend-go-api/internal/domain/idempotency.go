@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyKey remembers the outcome of a request so that a client retry
+// carrying the same Idempotency-Key header gets the original response back.
+type IdempotencyKey struct {
+	AccountID    string
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	// LockedUntil is when the reservation of a key without a response ends.
+	// After that the key can be taken over, so a request that died before
+	// storing its response does not block retries until the key expires.
+	LockedUntil time.Time
+}
+
+func NewIdempotencyKey(accountID, key, requestHash string, ttl, lease time.Duration) (*IdempotencyKey, error) {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	now := time.Now()
+
+	return &IdempotencyKey{
+		AccountID:   accountID,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+		LockedUntil: now.Add(lease),
+	}, nil
+}
+
+// Matches reports whether requestHash belongs to the request that first
+// used this key.
+func (k *IdempotencyKey) Matches(requestHash string) bool {
+	return k.RequestHash == requestHash
+}
+
+// Completed reports whether the response of the request was stored. Keys
+// that are not completed belong to a request still in progress.
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+func (k *IdempotencyKey) Complete(statusCode int, responseBody []byte) {
+	k.StatusCode = statusCode
+	k.ResponseBody = responseBody
+}
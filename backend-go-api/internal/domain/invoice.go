@@ -1,7 +1,8 @@
 package domain
 
 import (
-	"math/rand"
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,79 +14,172 @@ const (
 	StatusPending  Status = "pending"
 	StatusApproved Status = "approved"
 	StatusRejected Status = "rejected"
+
+	StatusPartiallyRefunded Status = "partially_refunded"
+	StatusRefunded          Status = "refunded"
+
+	// StatusAuthorized invoices hold an approval that still has to be
+	// captured before funds are credited to the merchant.
+	StatusAuthorized Status = "authorized"
+	StatusVoided     Status = "voided"
+	StatusExpired    Status = "expired"
 )
 
 type Invoice struct {
 	ID             string
 	AccountID      string
-	Amount         float64
+	Amount         Money
+	CapturedAmount Money
+	RefundedAmount Money
 	Status         Status
-	Description    string
-	PaymentType    string
-	CardLastDigits string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-}
-
-type CreditCard struct {
-	Number         string
-	CVV            string
-	ExpiryMonth    int
-	ExpiryYear     int
-	CardholderName string
+	// AutoCapture invoices are captured in full as soon as they are
+	// approved; otherwise approval only authorizes the amount.
+	AutoCapture            bool
+	AuthorizationExpiresAt *time.Time
+	Description            string
+	PaymentType            string
+	CardLastDigits         string
+	CardBrand              CardBrand
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
 }
 
-func NewInvoice(accountID string, amount float64, description string, paymentType string, card CreditCard) (*Invoice, error) {
-	if amount <= 0 {
+func NewInvoice(accountID string, amount Money, description string, paymentType string, card CreditCard, autoCapture bool) (*Invoice, error) {
+	if !amount.IsPositive() {
 		return nil, ErrInvalidAmount
 	}
 
-	lastDigits := card.Number[len(card.Number)-4:]
+	if err := ValidateCurrency(amount.Currency); err != nil {
+		return nil, err
+	}
+
+	brand, err := card.Validate(time.Now())
+	if err != nil {
+		return nil, err
+	}
 
 	return &Invoice{
 		ID:             uuid.New().String(),
 		AccountID:      accountID,
 		Amount:         amount,
+		CapturedAmount: NewMoney(0, amount.Currency),
+		RefundedAmount: NewMoney(0, amount.Currency),
 		Status:         StatusPending,
+		AutoCapture:    autoCapture,
 		Description:    description,
 		PaymentType:    paymentType,
-		CardLastDigits: lastDigits,
+		CardLastDigits: card.LastDigits(),
+		CardBrand:      brand,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}, nil
 }
 
-func (i *Invoice) Process() error {
-	if i.Amount >= 10000 {
+// Process leaves invoices that require review pending for the anti-fraud
+// service and asks the payment processor to decide the others.
+func (i *Invoice) Process(ctx context.Context, thresholds ReviewThresholds, processor PaymentProcessor, card CreditCard, authorizationTTL time.Duration) error {
+	if thresholds.RequiresReview(i.Amount) {
 		i.Status = StatusPending
 		return nil
 	}
 
-	randomSource := rand.New(rand.NewSource(time.Now().Unix()))
+	decision, err := processor.Authorize(ctx, PaymentRequest{
+		InvoiceID: i.ID,
+		Amount:    i.Amount,
+		Card:      card,
+	})
+	if err != nil {
+		return err
+	}
 
-	var newStatus Status
-	if randomSource.Float64() <= 0.7 {
-		newStatus = StatusApproved
-	} else {
-		newStatus = StatusRejected
+	if decision == PaymentApproved {
+		return i.Approve(authorizationTTL)
+	}
+
+	return i.Reject()
+}
+
+// Approve captures the full amount of auto-capture invoices. Other invoices
+// become authorized until captured, voided or expired after authorizationTTL.
+func (i *Invoice) Approve(authorizationTTL time.Duration) error {
+	if i.Status != StatusPending {
+		return ErrInvalidStatus
 	}
 
-	i.Status = newStatus
+	now := time.Now()
+	i.UpdatedAt = now
+
+	if i.AutoCapture {
+		i.Status = StatusApproved
+		i.CapturedAmount = i.Amount
+		return nil
+	}
+
+	expiresAt := now.Add(authorizationTTL)
+	i.Status = StatusAuthorized
+	i.AuthorizationExpiresAt = &expiresAt
 
 	return nil
 }
 
-func (i *Invoice) Approve() error {
-	if i.Status != StatusPending {
+// Capture settles an authorization for amount, which may be less than the
+// authorized amount; the remainder is released.
+func (i *Invoice) Capture(amount Money) error {
+	if i.Status != StatusAuthorized {
 		return ErrInvalidStatus
 	}
 
+	if i.AuthorizationExpired(time.Now()) {
+		return ErrAuthorizationExpired
+	}
+
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+
+	cmp, err := amount.Cmp(i.Amount)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return ErrCaptureExceedsAmount
+	}
+
 	i.Status = StatusApproved
+	i.CapturedAmount = amount
 	i.UpdatedAt = time.Now()
 
 	return nil
 }
 
+// Void cancels an authorization that has not been captured.
+func (i *Invoice) Void() error {
+	if i.Status != StatusAuthorized {
+		return ErrInvalidStatus
+	}
+
+	i.Status = StatusVoided
+	i.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// Expire releases an authorization whose capture window has passed.
+func (i *Invoice) Expire(now time.Time) error {
+	if i.Status != StatusAuthorized || !i.AuthorizationExpired(now) {
+		return ErrInvalidStatus
+	}
+
+	i.Status = StatusExpired
+	i.UpdatedAt = now
+
+	return nil
+}
+
+func (i *Invoice) AuthorizationExpired(now time.Time) bool {
+	return i.AuthorizationExpiresAt != nil && !now.Before(*i.AuthorizationExpiresAt)
+}
+
 func (i *Invoice) Reject() error {
 	if i.Status != StatusPending {
 		return ErrInvalidStatus
@@ -96,3 +190,123 @@ func (i *Invoice) Reject() error {
 
 	return nil
 }
+
+// HasOutcome reports whether the invoice already left pending with the
+// given analysis result (approved or rejected). Later steps such as
+// capture or refunds still count as an approval.
+func (i *Invoice) HasOutcome(result Status) bool {
+	switch result {
+	case StatusApproved:
+		switch i.Status {
+		case StatusApproved, StatusAuthorized, StatusPartiallyRefunded, StatusRefunded, StatusVoided, StatusExpired:
+			return true
+		}
+	case StatusRejected:
+		return i.Status == StatusRejected
+	}
+
+	return false
+}
+
+// RefundableAmount is what is left of the captured amount after refunds.
+func (i *Invoice) RefundableAmount() Money {
+	return NewMoney(i.CapturedAmount.Amount-i.RefundedAmount.Amount, i.Amount.Currency)
+}
+
+// Refund returns amount to the cardholder. Several partial refunds are
+// allowed as long as their total does not exceed the captured amount.
+func (i *Invoice) Refund(amount Money) (*Refund, error) {
+	if i.Status != StatusApproved && i.Status != StatusPartiallyRefunded {
+		return nil, ErrInvoiceNotRefundable
+	}
+
+	if !amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+
+	cmp, err := amount.Cmp(i.RefundableAmount())
+	if err != nil {
+		return nil, err
+	}
+	if cmp > 0 {
+		return nil, ErrRefundExceedsAmount
+	}
+
+	i.RefundedAmount = NewMoney(i.RefundedAmount.Amount+amount.Amount, i.Amount.Currency)
+	if i.RefundableAmount().IsZero() {
+		i.Status = StatusRefunded
+	} else {
+		i.Status = StatusPartiallyRefunded
+	}
+	i.UpdatedAt = time.Now()
+
+	return NewRefund(i.ID, i.AccountID, amount), nil
+}
+
+// InvoiceSort is the order of an invoice listing. A leading "-" sorts in
+// descending order; ties are broken by id in the same direction.
+type InvoiceSort string
+
+const (
+	InvoiceSortCreatedAtDesc InvoiceSort = "-created_at"
+	InvoiceSortCreatedAtAsc  InvoiceSort = "created_at"
+	InvoiceSortAmountDesc    InvoiceSort = "-amount"
+	InvoiceSortAmountAsc     InvoiceSort = "amount"
+)
+
+var invoiceStatuses = map[Status]bool{
+	StatusPending:           true,
+	StatusApproved:          true,
+	StatusRejected:          true,
+	StatusPartiallyRefunded: true,
+	StatusRefunded:          true,
+	StatusAuthorized:        true,
+	StatusVoided:            true,
+	StatusExpired:           true,
+}
+
+// InvoiceFilter narrows an invoice listing. Zero values mean no filter.
+// CreatedFrom is inclusive and CreatedTo exclusive.
+type InvoiceFilter struct {
+	Statuses    []Status
+	PaymentType string
+	Currency    string
+	MinAmount   *Money
+	MaxAmount   *Money
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        InvoiceSort
+}
+
+// Validate checks the filter and fills in the default sort.
+func (f *InvoiceFilter) Validate() error {
+	for _, status := range f.Statuses {
+		if !invoiceStatuses[status] {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidInvoiceFilter, status)
+		}
+	}
+
+	if f.Currency != "" {
+		if err := ValidateCurrency(f.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInvoiceFilter, err)
+		}
+	}
+
+	if f.MinAmount != nil && f.MaxAmount != nil && f.MinAmount.Amount > f.MaxAmount.Amount {
+		return fmt.Errorf("%w: min_amount is greater than max_amount", ErrInvalidInvoiceFilter)
+	}
+
+	if f.CreatedFrom != nil && f.CreatedTo != nil && !f.CreatedFrom.Before(*f.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", ErrInvalidInvoiceFilter)
+	}
+
+	switch f.Sort {
+	case "":
+		f.Sort = InvoiceSortCreatedAtDesc
+	case InvoiceSortCreatedAtDesc, InvoiceSortCreatedAtAsc, InvoiceSortAmountDesc, InvoiceSortAmountAsc:
+	default:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidInvoiceFilter, f.Sort)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "testing"
+
+func TestInvoiceHasOutcome(t *testing.T) {
+	tests := []struct {
+		status       Status
+		wantApproved bool
+		wantRejected bool
+	}{
+		{StatusPending, false, false},
+		{StatusApproved, true, false},
+		{StatusAuthorized, true, false},
+		{StatusPartiallyRefunded, true, false},
+		{StatusRefunded, true, false},
+		// Voided and expired authorizations were approved before
+		{StatusVoided, true, false},
+		{StatusExpired, true, false},
+		{StatusRejected, false, true},
+	}
+
+	for _, tt := range tests {
+		invoice := &Invoice{Status: tt.status}
+		if got := invoice.HasOutcome(StatusApproved); got != tt.wantApproved {
+			t.Errorf("%s invoice: HasOutcome(approved) = %v, want %v", tt.status, got, tt.wantApproved)
+		}
+		if got := invoice.HasOutcome(StatusRejected); got != tt.wantRejected {
+			t.Errorf("%s invoice: HasOutcome(rejected) = %v, want %v", tt.status, got, tt.wantRejected)
+		}
+		if invoice.HasOutcome(StatusPending) {
+			t.Errorf("%s invoice: HasOutcome(pending) = true, want false", tt.status)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LedgerAccount names the book an entry is posted to. Merchant balances are
+// liabilities of the gateway; the others are gateway-side books.
+type LedgerAccount string
+
+const (
+	LedgerAccountMerchant       LedgerAccount = "merchant_balance"
+	LedgerAccountSettlement     LedgerAccount = "settlement"
+	LedgerAccountOpeningBalance LedgerAccount = "opening_balance"
+)
+
+type EntryDirection string
+
+const (
+	Debit  EntryDirection = "debit"
+	Credit EntryDirection = "credit"
+)
+
+type LedgerEntryType string
+
+const (
+	LedgerEntryInvoicePayment LedgerEntryType = "invoice_payment"
+	LedgerEntryRefund         LedgerEntryType = "refund"
+	LedgerEntryOpening        LedgerEntryType = "opening_balance"
+)
+
+// LedgerEntry is a single immutable posting. Entries are only ever written
+// as part of a balanced LedgerTransaction.
+type LedgerEntry struct {
+	ID            string
+	TransactionID string
+	AccountID     string
+	LedgerAccount LedgerAccount
+	Direction     EntryDirection
+	Amount        Money
+	Type          LedgerEntryType
+	ReferenceID   string
+	Description   string
+	CreatedAt     time.Time
+}
+
+// LedgerTransaction is a set of entries whose debits equal their credits in
+// every currency.
+type LedgerTransaction struct {
+	ID        string
+	AccountID string
+	Entries   []*LedgerEntry
+}
+
+type posting struct {
+	ledgerAccount LedgerAccount
+	direction     EntryDirection
+}
+
+func newLedgerTransaction(
+	accountID string,
+	entryType LedgerEntryType,
+	referenceID string,
+	description string,
+	amount Money,
+	postings ...posting,
+) (*LedgerTransaction, error) {
+	if !amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+
+	transaction := &LedgerTransaction{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+	}
+
+	now := time.Now()
+	for _, p := range postings {
+		transaction.Entries = append(transaction.Entries, &LedgerEntry{
+			ID:            uuid.New().String(),
+			TransactionID: transaction.ID,
+			AccountID:     accountID,
+			LedgerAccount: p.ledgerAccount,
+			Direction:     p.direction,
+			Amount:        amount,
+			Type:          entryType,
+			ReferenceID:   referenceID,
+			Description:   description,
+			CreatedAt:     now,
+		})
+	}
+
+	if err := transaction.Validate(); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// NewInvoicePaymentTransaction credits the merchant with funds received for
+// an invoice.
+func NewInvoicePaymentTransaction(accountID, invoiceID string, amount Money) (*LedgerTransaction, error) {
+	return newLedgerTransaction(accountID, LedgerEntryInvoicePayment, invoiceID, "invoice payment", amount,
+		posting{LedgerAccountSettlement, Debit},
+		posting{LedgerAccountMerchant, Credit},
+	)
+}
+
+// NewRefundTransaction returns funds of an invoice to the cardholder.
+func NewRefundTransaction(accountID, invoiceID string, amount Money) (*LedgerTransaction, error) {
+	return newLedgerTransaction(accountID, LedgerEntryRefund, invoiceID, "invoice refund", amount,
+		posting{LedgerAccountMerchant, Debit},
+		posting{LedgerAccountSettlement, Credit},
+	)
+}
+
+// Validate checks that the transaction has entries and that debits and
+// credits balance per currency.
+func (t *LedgerTransaction) Validate() error {
+	if len(t.Entries) < 2 {
+		return ErrUnbalancedLedgerTransaction
+	}
+
+	totals := make(map[string]int64)
+	for _, entry := range t.Entries {
+		if !entry.Amount.IsPositive() {
+			return ErrInvalidAmount
+		}
+
+		switch entry.Direction {
+		case Debit:
+			totals[entry.Amount.Currency] += entry.Amount.Amount
+		case Credit:
+			totals[entry.Amount.Currency] -= entry.Amount.Amount
+		default:
+			return ErrUnbalancedLedgerTransaction
+		}
+	}
+
+	for _, total := range totals {
+		if total != 0 {
+			return ErrUnbalancedLedgerTransaction
+		}
+	}
+
+	return nil
+}
+
+// MerchantBalanceChanges returns the net effect of the transaction on the
+// merchant balance, one Money per currency touched.
+func (t *LedgerTransaction) MerchantBalanceChanges() []Money {
+	changes := make(map[string]Money)
+	for _, entry := range t.Entries {
+		if entry.LedgerAccount != LedgerAccountMerchant {
+			continue
+		}
+
+		change := changes[entry.Amount.Currency]
+		change.Currency = entry.Amount.Currency
+		if entry.Direction == Credit {
+			change.Amount += entry.Amount.Amount
+		} else {
+			change.Amount -= entry.Amount.Amount
+		}
+		changes[entry.Amount.Currency] = change
+	}
+
+	result := make([]Money, 0, len(changes))
+	for _, currency := range sortedCurrencies(changes) {
+		result = append(result, changes[currency])
+	}
+
+	return result
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func ledgerEntry(ledgerAccount LedgerAccount, direction EntryDirection, amount Money) *LedgerEntry {
+	return &LedgerEntry{LedgerAccount: ledgerAccount, Direction: direction, Amount: amount}
+}
+
+func TestLedgerTransactionValidate(t *testing.T) {
+	brl := func(amount int64) Money { return NewMoney(amount, CurrencyBRL) }
+	usd := func(amount int64) Money { return NewMoney(amount, CurrencyUSD) }
+
+	tests := []struct {
+		name    string
+		entries []*LedgerEntry
+		wantErr error
+	}{
+		{"balanced", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1000)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(1000)),
+		}, nil},
+		{"balanced over several entries", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1000)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(600)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(400)),
+		}, nil},
+		{"balanced per currency", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1000)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(1000)),
+			ledgerEntry(LedgerAccountSettlement, Debit, usd(50)),
+			ledgerEntry(LedgerAccountMerchant, Credit, usd(50)),
+		}, nil},
+		{"no entries", nil, ErrUnbalancedLedgerTransaction},
+		{"single entry", []*LedgerEntry{
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(1000)),
+		}, ErrUnbalancedLedgerTransaction},
+		{"debits exceed credits", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1001)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(1000)),
+		}, ErrUnbalancedLedgerTransaction},
+		{"same totals in different currencies", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1000)),
+			ledgerEntry(LedgerAccountMerchant, Credit, usd(1000)),
+		}, ErrUnbalancedLedgerTransaction},
+		{"two debits", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1000)),
+			ledgerEntry(LedgerAccountMerchant, Debit, brl(1000)),
+		}, ErrUnbalancedLedgerTransaction},
+		{"unknown direction", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(1000)),
+			ledgerEntry(LedgerAccountMerchant, "sideways", brl(1000)),
+		}, ErrUnbalancedLedgerTransaction},
+		{"zero amount", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(0)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(0)),
+		}, ErrInvalidAmount},
+		{"negative amounts", []*LedgerEntry{
+			ledgerEntry(LedgerAccountSettlement, Debit, brl(-1000)),
+			ledgerEntry(LedgerAccountMerchant, Credit, brl(-1000)),
+		}, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		transaction := &LedgerTransaction{Entries: tt.entries}
+		if err := transaction.Validate(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Validate() = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewInvoicePaymentTransaction(t *testing.T) {
+	transaction, err := NewInvoicePaymentTransaction("account-1", "invoice-1", NewMoney(1050, CurrencyBRL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transaction.Entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(transaction.Entries))
+	}
+	for _, entry := range transaction.Entries {
+		if entry.TransactionID != transaction.ID || entry.AccountID != "account-1" || entry.ReferenceID != "invoice-1" || entry.Type != LedgerEntryInvoicePayment {
+			t.Errorf("entry = %+v, want it to belong to the invoice payment", entry)
+		}
+	}
+
+	changes := transaction.MerchantBalanceChanges()
+	if len(changes) != 1 || changes[0] != NewMoney(1050, CurrencyBRL) {
+		t.Errorf("merchant balance changes = %v, want +10.50 BRL", changes)
+	}
+}
+
+func TestNewRefundTransaction(t *testing.T) {
+	transaction, err := NewRefundTransaction("account-1", "invoice-1", NewMoney(300, CurrencyUSD))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := transaction.MerchantBalanceChanges()
+	if len(changes) != 1 || changes[0] != NewMoney(-300, CurrencyUSD) {
+		t.Errorf("merchant balance changes = %v, want -3.00 USD", changes)
+	}
+}
+
+func TestNewLedgerTransactionRejectsNonPositiveAmounts(t *testing.T) {
+	for _, amount := range []int64{0, -100} {
+		if _, err := NewInvoicePaymentTransaction("account-1", "invoice-1", NewMoney(amount, CurrencyBRL)); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("amount %d: error = %v, want ErrInvalidAmount", amount, err)
+		}
+	}
+}
+
+func TestMerchantBalanceChangesPerCurrency(t *testing.T) {
+	transaction := &LedgerTransaction{Entries: []*LedgerEntry{
+		ledgerEntry(LedgerAccountMerchant, Credit, NewMoney(500, CurrencyUSD)),
+		ledgerEntry(LedgerAccountSettlement, Debit, NewMoney(500, CurrencyUSD)),
+		ledgerEntry(LedgerAccountMerchant, Credit, NewMoney(1000, CurrencyBRL)),
+		ledgerEntry(LedgerAccountMerchant, Debit, NewMoney(400, CurrencyBRL)),
+		ledgerEntry(LedgerAccountSettlement, Debit, NewMoney(600, CurrencyBRL)),
+	}}
+
+	changes := transaction.MerchantBalanceChanges()
+	want := []Money{NewMoney(600, CurrencyBRL), NewMoney(500, CurrencyUSD)}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("MerchantBalanceChanges() = %v, want %v", changes, want)
+	}
+}
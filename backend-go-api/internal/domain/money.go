@@ -0,0 +1,232 @@
+package domain
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when an amount arrives without a currency.
+const DefaultCurrency = "BRL"
+
+// minorUnitsPerMajor is the scale of every supported currency (two decimal
+// places, matching the DECIMAL(10,2) columns).
+const minorUnitsPerMajor = 100
+
+// MaxMoneyAmount is the largest amount, in minor units, that fits the
+// DECIMAL(10,2) columns: 99999999.99.
+const MaxMoneyAmount = 9_999_999_999
+
+var decimalAmountPattern = regexp.MustCompile(`^-?\d+(\.\d{1,2})?$`)
+
+// Money is an exact amount in the minor units (cents) of an ISO-4217
+// currency. It is a value type; arithmetic returns new values.
+//
+// In JSON, Money is written as a decimal string with at most two decimal
+// places ("100.50"). It is read either from such a string or from a JSON
+// integer of minor units (10050). Other JSON numbers are rejected with
+// ErrMoneyNotString, since 100.5 cannot be told apart from a float. The
+// currency travels in a separate field.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "100.50" into Money. Amounts
+// beyond MaxMoneyAmount in either direction return ErrAmountTooLarge.
+func ParseMoney(value, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if !decimalAmountPattern.MatchString(value) {
+		return Money{}, ErrInvalidMoney
+	}
+
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	fraction = (fraction + "00")[:2]
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || major > math.MaxInt64/minorUnitsPerMajor-1 {
+		return Money{}, ErrInvalidMoney
+	}
+	minor, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+
+	amount := major*minorUnitsPerMajor + minor
+	if negative {
+		amount = -amount
+	}
+
+	return newBoundedMoney(amount, currency)
+}
+
+// newBoundedMoney returns amount as Money unless it does not fit the
+// amount columns.
+func newBoundedMoney(amount int64, currency string) (Money, error) {
+	if amount > MaxMoneyAmount || amount < -MaxMoneyAmount {
+		return Money{}, ErrAmountTooLarge
+	}
+
+	return NewMoney(amount, currency), nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return Money{}, err
+	}
+
+	return NewMoney(m.Amount+other.Amount, m.currency(other)), nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return Money{}, err
+	}
+
+	return NewMoney(m.Amount-other.Amount, m.currency(other)), nil
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// String formats the amount as a decimal with two places, e.g. "100.50".
+func (m Money) String() string {
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = uint64(-m.Amount)
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorUnitsPerMajor, amount%minorUnitsPerMajor)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return ErrInvalidMoney
+		}
+
+		parsed, err := ParseMoney(value, m.Currency)
+		if err != nil {
+			return err
+		}
+
+		m.Amount = parsed.Amount
+		return nil
+	}
+
+	// Integers are minor units; anything else, including 1e3, is refused
+	amount, err := strconv.ParseInt(string(data), 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return ErrAmountTooLarge
+	}
+	if err != nil {
+		return ErrMoneyNotString
+	}
+
+	parsed, err := newBoundedMoney(amount, m.Currency)
+	if err != nil {
+		return err
+	}
+
+	m.Amount = parsed.Amount
+	return nil
+}
+
+// Value stores the amount in a DECIMAL column. The currency is not part of
+// the column value.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a DECIMAL column, keeping whatever currency m already holds.
+func (m *Money) Scan(src any) error {
+	var value string
+	switch v := src.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case int64:
+		m.Amount = v * minorUnitsPerMajor
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	parsed, err := ParseMoney(value, m.Currency)
+	if err != nil {
+		return err
+	}
+
+	m.Amount = parsed.Amount
+	return nil
+}
+
+// checkCurrency allows mixing a value without currency (e.g. zero balances
+// freshly created) with any currency.
+func (m Money) checkCurrency(other Money) error {
+	if m.Currency != "" && other.Currency != "" && m.Currency != other.Currency {
+		return ErrCurrencyMismatch
+	}
+
+	return nil
+}
+
+func (m Money) currency(other Money) string {
+	if m.Currency != "" {
+		return m.Currency
+	}
+
+	return other.Currency
+}
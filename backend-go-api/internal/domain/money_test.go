@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr error
+	}{
+		{"100", 10000, nil},
+		{"100.5", 10050, nil},
+		{"100.50", 10050, nil},
+		{"0.01", 1, nil},
+		{"0", 0, nil},
+		{" 12.34 ", 1234, nil},
+		{"-5.25", -525, nil},
+		{"-0.01", -1, nil},
+		{"100.505", 0, ErrInvalidMoney},
+		{"1,50", 0, ErrInvalidMoney},
+		{"1e3", 0, ErrInvalidMoney},
+		{".50", 0, ErrInvalidMoney},
+		{"100.", 0, ErrInvalidMoney},
+		{"+1.00", 0, ErrInvalidMoney},
+		{"", 0, ErrInvalidMoney},
+		{"abc", 0, ErrInvalidMoney},
+		{"92233720368547758.07", 0, ErrInvalidMoney},
+		{"99999999.99", MaxMoneyAmount, nil},
+		{"-99999999.99", -MaxMoneyAmount, nil},
+		{"100000000.00", 0, ErrAmountTooLarge},
+		{"-100000000", 0, ErrAmountTooLarge},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, CurrencyBRL)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (got.Amount != tt.want || got.Currency != CurrencyBRL) {
+			t.Errorf("ParseMoney(%q) = %d %s, want %d BRL", tt.value, got.Amount, got.Currency, tt.want)
+		}
+	}
+}
+
+// Every supported currency has two decimal places, so the same string gives
+// the same minor units whatever the currency.
+func TestParseMoneyScalePerCurrency(t *testing.T) {
+	for currency := range supportedCurrencies {
+		got, err := ParseMoney("1.23", currency)
+		if err != nil {
+			t.Fatalf("ParseMoney(1.23, %s): %v", currency, err)
+		}
+		if got.Amount != 123 || got.Currency != currency {
+			t.Errorf("ParseMoney(1.23, %s) = %d %s, want 123 %s", currency, got.Amount, got.Currency, currency)
+		}
+		if _, err := ParseMoney("1.234", currency); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("ParseMoney(1.234, %s) error = %v, want ErrInvalidMoney", currency, err)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{10050, "100.50"},
+		{-525, "-5.25"},
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount, CurrencyBRL).String(); got != tt.want {
+			t.Errorf("NewMoney(%d).String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    int64
+		wantErr error
+	}{
+		{`"100.50"`, 10050, nil},
+		{`"100"`, 10000, nil},
+		{`"-1.00"`, -100, nil},
+		{`null`, 0, nil},
+		{`10050`, 10050, nil},
+		{`0`, 0, nil},
+		{`-1`, -1, nil},
+		{`9999999999`, MaxMoneyAmount, nil},
+		{`10000000000`, 0, ErrAmountTooLarge},
+		{`100000000000000000000`, 0, ErrAmountTooLarge},
+		{`100.5`, 0, ErrMoneyNotString},
+		{`100.0`, 0, ErrMoneyNotString},
+		{`1e3`, 0, ErrMoneyNotString},
+		{`"100000000.00"`, 0, ErrAmountTooLarge},
+		{`"100.505"`, 0, ErrInvalidMoney},
+		{`"1e2"`, 0, ErrInvalidMoney},
+		{`true`, 0, ErrMoneyNotString},
+	}
+
+	for _, tt := range tests {
+		var input struct {
+			Amount Money `json:"amount"`
+		}
+		err := json.Unmarshal([]byte(`{"amount": `+tt.json+`}`), &input)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("unmarshal %s error = %v, want %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if err == nil && input.Amount.Amount != tt.want {
+			t.Errorf("unmarshal %s = %d, want %d", tt.json, input.Amount.Amount, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	data, err := json.Marshal(NewMoney(10050, CurrencyUSD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"100.50"` {
+		t.Fatalf("marshal = %s, want \"100.50\"", data)
+	}
+
+	var got Money
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Amount != 10050 {
+		t.Errorf("round trip = %d, want 10050", got.Amount)
+	}
+}
+
+func TestMoneyArithmeticChecksCurrency(t *testing.T) {
+	brl := NewMoney(100, CurrencyBRL)
+
+	if _, err := brl.Add(NewMoney(100, CurrencyUSD)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("BRL + USD error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := brl.Cmp(NewMoney(100, CurrencyEUR)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("BRL cmp EUR error = %v, want ErrCurrencyMismatch", err)
+	}
+
+	sum, err := brl.Add(NewMoney(50, ""))
+	if err != nil || sum != NewMoney(150, CurrencyBRL) {
+		t.Errorf("BRL + unset currency = %v %v, want 1.50 BRL", sum, err)
+	}
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSent    OutboxStatus = "sent"
+	OutboxStatusFailed  OutboxStatus = "failed"
+)
+
+// OutboxMessage is an event persisted in the same transaction as the
+// aggregate that produced it and later published to Kafka by the relay.
+type OutboxMessage struct {
+	ID          string
+	AggregateID string
+	EventType   string
+	Payload     []byte
+	// TraceContext carries the W3C trace context (traceparent, tracestate)
+	// of the request that produced the message, so publishing it joins the
+	// same trace.
+	TraceContext  map[string]string
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+func NewOutboxMessage(aggregateID, eventType string, payload []byte) *OutboxMessage {
+	now := time.Now()
+
+	return &OutboxMessage{
+		ID:            uuid.New().String(),
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func (m *OutboxMessage) MarkSent() {
+	now := time.Now()
+	m.Status = OutboxStatusSent
+	m.LastError = ""
+	m.SentAt = &now
+}
+
+// MarkFailed records a failed publish attempt. The message is retried at
+// retryAt unless giveUp is set, in which case it is parked as failed.
+func (m *OutboxMessage) MarkFailed(err error, retryAt time.Time, giveUp bool) {
+	m.Attempts++
+	m.LastError = err.Error()
+	m.NextAttemptAt = retryAt
+
+	if giveUp {
+		m.Status = OutboxStatusFailed
+	}
+}
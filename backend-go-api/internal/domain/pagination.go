@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Cursor marks a position in a listing ordered by (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// ClampPageLimit applies the default and maximum page sizes.
+func ClampPageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultPageLimit
+	case limit > MaxPageLimit:
+		return MaxPageLimit
+	default:
+		return limit
+	}
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "context"
+
+// PaymentDecision is the answer of the acquirer to an authorization request.
+type PaymentDecision string
+
+const (
+	PaymentApproved          PaymentDecision = "approved"
+	PaymentDeclined          PaymentDecision = "declined"
+	PaymentInsufficientFunds PaymentDecision = "insufficient_funds"
+)
+
+// PaymentRequest carries what an acquirer needs to authorize an invoice.
+type PaymentRequest struct {
+	InvoiceID string
+	Amount    Money
+	Card      CreditCard
+}
+
+// PaymentProcessor authorizes payments with an acquirer. Implementations
+// return ErrPaymentProcessorTimeout when the acquirer gives no answer, so
+// the invoice is not decided either way. Authorize gives up when ctx is
+// cancelled.
+type PaymentProcessor interface {
+	Authorize(ctx context.Context, request PaymentRequest) (PaymentDecision, error)
+}
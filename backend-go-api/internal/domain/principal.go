@@ -0,0 +1,27 @@
+package domain
+
+import "context"
+
+// Principal is the caller of a request, resolved once from its api key by
+// the authentication middleware.
+type Principal struct {
+	AccountID string
+	APIKeyID  string
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the request, or
+// ErrUnauthenticated when the request went through no authentication.
+func PrincipalFromContext(ctx context.Context) (Principal, error) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	return principal, nil
+}
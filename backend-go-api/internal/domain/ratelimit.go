@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Requests per Period, with bursts of up to Requests. It
+// is a token bucket holding Requests tokens and refilled at Requests/Period,
+// computed as a generic cell rate algorithm: the whole bucket state is the
+// theoretical arrival time (TAT) of the next request, the moment the bucket
+// will be full again.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// RateLimitResult is the outcome of taking a token, with the values of the
+// RateLimit-* response headers.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     RateLimit
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the TAT of every bucket. Take must be atomic for a
+// key, even across instances sharing the store.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// ParseRateLimit reads limits written as "<requests>/<period>", such as
+// "100/1m". The period is a Go duration.
+func ParseRateLimit(value string) (RateLimit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return RateLimit{}, ErrInvalidRateLimit
+	}
+
+	limit := RateLimit{}
+
+	var err error
+	if limit.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || limit.Requests <= 0 {
+		return RateLimit{}, ErrInvalidRateLimit
+	}
+	if limit.Period, err = time.ParseDuration(strings.TrimSpace(period)); err != nil || limit.Period <= 0 {
+		return RateLimit{}, ErrInvalidRateLimit
+	}
+
+	return limit, nil
+}
+
+// Interval is the time it takes to refill one token.
+func (l RateLimit) Interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Take spends a token of the bucket whose TAT is tat. It returns the new TAT,
+// which is tat itself when the request is denied.
+func (l RateLimit) Take(tat, now time.Time) (time.Time, RateLimitResult) {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(l.Interval())
+	if next.Sub(now) > l.Period {
+		return tat, l.Denied(tat, now)
+	}
+
+	return next, l.Allowed(next, now)
+}
+
+// Allowed describes an allowed request that moved the TAT to next.
+func (l RateLimit) Allowed(next, now time.Time) RateLimitResult {
+	return RateLimitResult{
+		Allowed:   true,
+		Limit:     l,
+		Remaining: int((l.Period - next.Sub(now)) / l.Interval()),
+		Reset:     next.Sub(now),
+	}
+}
+
+// Denied describes a request refused while the TAT is tat.
+func (l RateLimit) Denied(tat, now time.Time) RateLimitResult {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	retryAfter := tat.Add(l.Interval()).Sub(now) - l.Period
+	if retryAfter <= 0 {
+		retryAfter = l.Interval()
+	}
+
+	return RateLimitResult{
+		Limit:      l,
+		Reset:      tat.Sub(now),
+		RetryAfter: retryAfter,
+	}
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// tenPerTenSeconds refills one token a second.
+var tenPerTenSeconds = RateLimit{Requests: 10, Period: 10 * time.Second}
+
+// drain takes every token of an empty bucket at now and returns its TAT.
+func drain(t *testing.T, limit RateLimit, now time.Time) time.Time {
+	t.Helper()
+
+	var tat time.Time
+	for i := 1; i <= limit.Requests; i++ {
+		var result RateLimitResult
+		tat, result = limit.Take(tat, now)
+		if !result.Allowed {
+			t.Fatalf("request %d of the burst was denied", i)
+		}
+		if want := limit.Requests - i; result.Remaining != want {
+			t.Errorf("request %d: remaining = %d, want %d", i, result.Remaining, want)
+		}
+	}
+
+	return tat
+}
+
+func TestRateLimitTakeBurst(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	tat := drain(t, tenPerTenSeconds, now)
+
+	if want := now.Add(10 * time.Second); !tat.Equal(want) {
+		t.Errorf("tat after the burst = %v, want %v", tat, want)
+	}
+
+	next, result := tenPerTenSeconds.Take(tat, now)
+	if result.Allowed {
+		t.Fatal("request after the burst was allowed")
+	}
+	if !next.Equal(tat) {
+		t.Errorf("denied request moved the tat to %v, want %v", next, tat)
+	}
+	if result.Remaining != 0 || result.RetryAfter != time.Second || result.Reset != 10*time.Second {
+		t.Errorf("result = %+v, want 0 remaining, retry after 1s and reset in 10s", result)
+	}
+}
+
+func TestRateLimitTakeRefill(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		elapsed       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"no time", 0, false, 0, time.Second},
+		{"half a token", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"one token", time.Second, true, 0, 0},
+		{"three tokens", 3 * time.Second, true, 2, 0},
+		{"full bucket", 10 * time.Second, true, 9, 0},
+		{"long idle", time.Hour, true, 9, 0},
+	}
+
+	for _, tt := range tests {
+		tat := drain(t, tenPerTenSeconds, now)
+		later := now.Add(tt.elapsed)
+
+		_, result := tenPerTenSeconds.Take(tat, later)
+		if result.Allowed != tt.wantAllowed || result.Remaining != tt.wantRemaining || result.RetryAfter != tt.wantRetry {
+			t.Errorf("%s: result = %+v, want allowed %v, %d remaining, retry after %v",
+				tt.name, result, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+		}
+	}
+}
+
+func TestRateLimitTakeSpreadsRequests(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+	// One request per interval never runs out of tokens
+	var tat time.Time
+	for i := 0; i < 100; i++ {
+		var result RateLimitResult
+		tat, result = tenPerTenSeconds.Take(tat, now.Add(time.Duration(i)*time.Second))
+		if !result.Allowed || result.Remaining != 9 {
+			t.Fatalf("request %d: result = %+v, want allowed with 9 remaining", i, result)
+		}
+	}
+}
+
+func TestRateLimitDeniedRetryAfterIsPositive(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+	// A store may deny a request whose bucket was refilled meanwhile; the
+	// client still waits one interval instead of retrying at once
+	result := tenPerTenSeconds.Denied(now, now)
+	if result.Allowed || result.RetryAfter != time.Second {
+		t.Errorf("result = %+v, want denied with retry after 1s", result)
+	}
+}
+
+func TestRateLimitInterval(t *testing.T) {
+	limit := RateLimit{Requests: 3, Period: time.Second}
+	if got, want := limit.Interval(), time.Second/3; got != want {
+		t.Errorf("Interval() = %v, want %v", got, want)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr error
+	}{
+		{"100/1m", RateLimit{Requests: 100, Period: time.Minute}, nil},
+		{" 10 / 1h ", RateLimit{Requests: 10, Period: time.Hour}, nil},
+		{"1/500ms", RateLimit{Requests: 1, Period: 500 * time.Millisecond}, nil},
+		{"100", RateLimit{}, ErrInvalidRateLimit},
+		{"0/1m", RateLimit{}, ErrInvalidRateLimit},
+		{"-1/1m", RateLimit{}, ErrInvalidRateLimit},
+		{"100/0s", RateLimit{}, ErrInvalidRateLimit},
+		{"100/minute", RateLimit{}, ErrInvalidRateLimit},
+		{"many/1m", RateLimit{}, ErrInvalidRateLimit},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRateLimit(tt.value)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("ParseRateLimit(%q) = %+v, %v, want %+v, %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Refund struct {
+	ID        string
+	InvoiceID string
+	AccountID string
+	Amount    Money
+	CreatedAt time.Time
+}
+
+func NewRefund(invoiceID, accountID string, amount Money) *Refund {
+	return &Refund{
+		ID:        uuid.New().String(),
+		InvoiceID: invoiceID,
+		AccountID: accountID,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+}
@@ -1,15 +1,152 @@
 package domain
 
+import (
+	"context"
+	"time"
+)
+
 type AccountRepository interface {
-	CreateAccount(account *Account) error
-	FindByAPIKey(apiKey string) (*Account, error)
-	FindByID(id string) (*Account, error)
-	UpdateBalance(account *Account) error
+	CreateAccount(ctx context.Context, account *Account) error
+	FindByID(ctx context.Context, id string) (*Account, error)
+	// FindByIDForUpdate locks the account row for the rest of the transaction.
+	FindByIDForUpdate(ctx context.Context, id string) (*Account, error)
+	// Update stores the name, email and status of the account. It fails with
+	// ErrDuplicateEmail when another account holds the email.
+	Update(ctx context.Context, account *Account) error
+	// AddBalance atomically adds amount (which may be negative) to the
+	// account balance in amount's currency and returns the new balance. It
+	// fails with ErrInsufficientBalance rather than going below zero.
+	AddBalance(ctx context.Context, accountID string, amount Money) (Money, error)
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	// FindByKey returns the key whatever its state; callers check IsActive.
+	FindByKey(ctx context.Context, key string) (*APIKey, error)
+	// FindByAccountID lists the keys of the account oldest first.
+	FindByAccountID(ctx context.Context, accountID string) ([]*APIKey, error)
+	// Revoke stores the RevokedAt and ExpiresAt of a key not revoked yet.
+	Revoke(ctx context.Context, key *APIKey) error
+	// RevokeAll stops every key of the account from working at revokedAt,
+	// grace periods included.
+	RevokeAll(ctx context.Context, accountID string, revokedAt time.Time) error
+	// TouchLastUsed records that the key authenticated a request at usedAt.
+	TouchLastUsed(ctx context.Context, id string, usedAt time.Time) error
 }
 
 type InvoiceRepository interface {
-	CreateInvoice(invoice *Invoice) error
-	FindByID(id string) (*Invoice, error)
-	FindByAccountID(accountID string) ([]*Invoice, error)
-	UpdateStatus(invoice *Invoice) error
+	CreateInvoice(ctx context.Context, invoice *Invoice) error
+	FindByID(ctx context.Context, id string) (*Invoice, error)
+	// FindByAccountID lists the invoices matching filter in the order of
+	// filter.Sort, starting after cursor when it is not nil.
+	FindByAccountID(ctx context.Context, accountID string, filter InvoiceFilter, cursor *Cursor, limit int) ([]*Invoice, error)
+	// StreamByAccountID calls fn for every invoice matching filter without
+	// loading them all in memory.
+	StreamByAccountID(ctx context.Context, accountID string, filter InvoiceFilter, fn func(*Invoice) error) error
+	// FindByIDForUpdate locks the invoice row for the rest of the transaction.
+	FindByIDForUpdate(ctx context.Context, id string) (*Invoice, error)
+	Update(ctx context.Context, invoice *Invoice) error
+	// FindExpiredAuthorizations locks authorized invoices whose capture
+	// window ended before the given time.
+	FindExpiredAuthorizations(ctx context.Context, before time.Time, limit int) ([]*Invoice, error)
+}
+
+type CardTokenRepository interface {
+	Create(ctx context.Context, token *CardToken) error
+	// FindByID only finds tokens that belong to the given account.
+	FindByID(ctx context.Context, accountID, id string) (*CardToken, error)
+	DeleteByAccountID(ctx context.Context, accountID string) error
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	UpdateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	// FindEndpoint only finds endpoints that belong to the given account.
+	FindEndpoint(ctx context.Context, accountID, id string) (*WebhookEndpoint, error)
+	FindEndpointsByAccountID(ctx context.Context, accountID string) ([]*WebhookEndpoint, error)
+	// FindSubscribedEndpoints returns the active endpoints of the account
+	// subscribed to eventType.
+	FindSubscribedEndpoints(ctx context.Context, accountID string, eventType WebhookEventType) ([]*WebhookEndpoint, error)
+
+	CreateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	// UpdateDelivery stores the delivery and ends the lease of the dispatcher
+	// that claimed it.
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	// RedeliverDelivery stores a delivery reset by Redeliver unless a
+	// dispatcher holds its lease at now. It reports whether it was stored.
+	RedeliverDelivery(ctx context.Context, delivery *WebhookDelivery, now time.Time) (bool, error)
+	// FindDelivery only finds deliveries made to the given endpoint.
+	FindDelivery(ctx context.Context, endpointID, id string) (*WebhookDelivery, error)
+	// FindDeliveries lists deliveries of the endpoint newest first, starting
+	// after cursor when it is not nil.
+	FindDeliveries(ctx context.Context, endpointID string, cursor *Cursor, limit int) ([]*WebhookDelivery, error)
+	// ClaimDueDeliveries leases pending deliveries due before the given time
+	// to the caller until leaseUntil, moving their next attempt there and
+	// skipping rows claimed concurrently by another dispatcher.
+	ClaimDueDeliveries(ctx context.Context, before time.Time, limit int, leaseUntil time.Time) ([]*WebhookDelivery, error)
+
+	AddAttempt(ctx context.Context, attempt *WebhookDeliveryAttempt) error
+	FindAttempts(ctx context.Context, deliveryID string) ([]*WebhookDeliveryAttempt, error)
+}
+
+type RefundRepository interface {
+	Create(ctx context.Context, refund *Refund) error
+}
+
+type OutboxRepository interface {
+	Save(ctx context.Context, message *OutboxMessage) error
+	// ClaimPending leases pending messages due before the given time to the
+	// caller by moving their next attempt to leaseUntil, skipping rows
+	// claimed concurrently by another relay.
+	ClaimPending(ctx context.Context, before time.Time, limit int, leaseUntil time.Time) ([]*OutboxMessage, error)
+	Update(ctx context.Context, message *OutboxMessage) error
+}
+
+type IdempotencyRepository interface {
+	// Reserve stores the key unless a live (not expired) entry already exists
+	// for the same account, either with a response or still reserved by its
+	// request. It reports whether the key was reserved.
+	Reserve(ctx context.Context, key *IdempotencyKey) (bool, error)
+	FindByKey(ctx context.Context, accountID, key string) (*IdempotencyKey, error)
+	SaveResponse(ctx context.Context, key *IdempotencyKey) error
+	// Release deletes a reserved key that has no response yet, so a failed
+	// request can be retried with it.
+	Release(ctx context.Context, key *IdempotencyKey) error
+}
+
+type LedgerRepository interface {
+	Append(ctx context.Context, transaction *LedgerTransaction) error
+	// FindByAccountID lists entries newest first, starting after cursor when
+	// it is not nil.
+	FindByAccountID(ctx context.Context, accountID string, cursor *Cursor, limit int) ([]*LedgerEntry, error)
+	// Balances derives the merchant balances from the ledger.
+	Balances(ctx context.Context, accountID string) ([]Money, error)
+}
+
+// SchemaRepository exposes the health of the database for readiness
+// checks.
+type SchemaRepository interface {
+	Ping(ctx context.Context) error
+	// MigrationVersion returns the applied migration version and whether
+	// the last migration failed halfway.
+	MigrationVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
+
+// Repositories groups the repositories bound to a single transaction.
+type Repositories struct {
+	Accounts        AccountRepository
+	APIKeys         APIKeyRepository
+	CardTokens      CardTokenRepository
+	Invoices        InvoiceRepository
+	Outbox          OutboxRepository
+	IdempotencyKeys IdempotencyRepository
+	Ledger          LedgerRepository
+	Refunds         RefundRepository
+	Webhooks        WebhookRepository
+}
+
+// UnitOfWork runs fn inside a database transaction. The transaction is
+// committed when fn returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context, repos *Repositories) error) error
 }
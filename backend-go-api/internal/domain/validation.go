@@ -0,0 +1,47 @@
+package domain
+
+import "strings"
+
+// FieldError tells why a request field was rejected. Field is the JSON name
+// of the field and Code a machine readable reason such as "required".
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError lists every field of a request that failed validation. It
+// matches ErrInvalidInput with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// inputError is a sentinel error about a single request field.
+type inputError struct {
+	message string
+}
+
+func newInputError(message string) error {
+	return &inputError{message: message}
+}
+
+func (e *inputError) Error() string {
+	return e.message
+}
+
+func (e *inputError) Unwrap() error {
+	return ErrInvalidInput
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+const cardTokenPrefix = "tok_"
+
+// CardToken is a card stored in the vault. The PAN is kept only encrypted
+// and the CVV is never stored.
+type CardToken struct {
+	ID              string
+	AccountID       string
+	EncryptedNumber []byte
+	Brand           CardBrand
+	LastDigits      string
+	ExpiryMonth     int
+	ExpiryYear      int
+	CardholderName  string
+	CreatedAt       time.Time
+}
+
+// NewCardToken validates the card and returns a token for it with an
+// opaque random ID. The caller encrypts the number into EncryptedNumber.
+func NewCardToken(accountID string, card CreditCard) (*CardToken, error) {
+	brand, err := card.Validate(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newCardTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CardToken{
+		ID:             id,
+		AccountID:      accountID,
+		Brand:          brand,
+		LastDigits:     card.LastDigits(),
+		ExpiryMonth:    card.ExpiryMonth,
+		ExpiryYear:     card.ExpiryYear,
+		CardholderName: card.CardholderName,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// Card rebuilds the card from the token and its decrypted number. The CVV
+// is left empty and the card is marked as stored.
+func (t *CardToken) Card(number string) CreditCard {
+	return CreditCard{
+		Number:         number,
+		ExpiryMonth:    t.ExpiryMonth,
+		ExpiryYear:     t.ExpiryYear,
+		CardholderName: t.CardholderName,
+		Stored:         true,
+	}
+}
+
+func newCardTokenID() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return cardTokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,306 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEventType string
+
+const (
+	WebhookEventInvoiceCreated  WebhookEventType = "invoice.created"
+	WebhookEventInvoiceApproved WebhookEventType = "invoice.approved"
+	WebhookEventInvoiceRejected WebhookEventType = "invoice.rejected"
+)
+
+var supportedWebhookEvents = map[WebhookEventType]bool{
+	WebhookEventInvoiceCreated:  true,
+	WebhookEventInvoiceApproved: true,
+	WebhookEventInvoiceRejected: true,
+}
+
+const webhookSecretPrefix = "whsec_"
+
+// WebhookEndpoint is a merchant URL subscribed to some event types.
+type WebhookEndpoint struct {
+	ID         string
+	AccountID  string
+	URL        string
+	Secret     string
+	EventTypes []WebhookEventType
+	Active     bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func NewWebhookEndpoint(accountID, rawURL string, eventTypes []WebhookEventType, policy WebhookURLPolicy) (*WebhookEndpoint, error) {
+	if err := policy.ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhookEventTypes(eventTypes); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return &WebhookEndpoint{
+		ID:         uuid.New().String(),
+		AccountID:  accountID,
+		URL:        rawURL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// Disable stops new deliveries to the endpoint.
+func (e *WebhookEndpoint) Disable() {
+	e.Active = false
+	e.UpdatedAt = time.Now()
+}
+
+// Subscribed reports whether the endpoint wants events of the given type.
+func (e *WebhookEndpoint) Subscribed(eventType WebhookEventType) bool {
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign returns the signature of a payload sent at timestamp, as the
+// hex-encoded HMAC-SHA256 of "<unix timestamp>.<payload>" keyed with the
+// endpoint secret.
+func (e *WebhookEndpoint) Sign(timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(e.Secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event to be delivered to one endpoint. It is
+// written in the same transaction as the change that produced the event.
+type WebhookDelivery struct {
+	ID            string
+	EndpointID    string
+	AccountID     string
+	EventType     WebhookEventType
+	Payload       []byte
+	Status        WebhookDeliveryStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+}
+
+func NewWebhookDelivery(endpoint *WebhookEndpoint, eventType WebhookEventType, payload []byte) *WebhookDelivery {
+	now := time.Now()
+
+	return &WebhookDelivery{
+		ID:            uuid.New().String(),
+		EndpointID:    endpoint.ID,
+		AccountID:     endpoint.AccountID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func (d *WebhookDelivery) MarkDelivered() {
+	now := time.Now()
+	d.Attempts++
+	d.Status = WebhookDeliverySucceeded
+	d.LastError = ""
+	d.DeliveredAt = &now
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at retryAt
+// unless giveUp is set.
+func (d *WebhookDelivery) MarkFailed(err error, retryAt time.Time, giveUp bool) {
+	d.Attempts++
+	d.LastError = err.Error()
+	d.NextAttemptAt = retryAt
+
+	if giveUp {
+		d.Status = WebhookDeliveryFailed
+	}
+}
+
+// Redeliver schedules the delivery to be sent again right away, with a
+// fresh retry budget. Past attempts are kept.
+func (d *WebhookDelivery) Redeliver() {
+	d.Status = WebhookDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+}
+
+// WebhookDeliveryAttempt records the outcome of one HTTP request made for
+// a delivery.
+type WebhookDeliveryAttempt struct {
+	ID             string
+	DeliveryID     string
+	ResponseStatus int
+	ResponseBody   string
+	Error          string
+	Duration       time.Duration
+	AttemptedAt    time.Time
+}
+
+func NewWebhookDeliveryAttempt(deliveryID string, attemptedAt time.Time) *WebhookDeliveryAttempt {
+	return &WebhookDeliveryAttempt{
+		ID:          uuid.New().String(),
+		DeliveryID:  deliveryID,
+		AttemptedAt: attemptedAt,
+	}
+}
+
+// nonPublicPrefixes are special-purpose ranges that netip does not classify
+// as private, loopback or link-local but that must not be reached either.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// WebhookURLPolicy decides which URLs webhook endpoints may use and which
+// addresses the dispatcher may connect to, so merchants cannot point the
+// gateway at internal services. The zero value is the production policy:
+// https only, to public addresses.
+type WebhookURLPolicy struct {
+	// AllowInsecure permits http URLs and loopback, private and link-local
+	// destinations, for local development.
+	AllowInsecure bool
+}
+
+// ValidateURL checks an endpoint URL. Hosts given as names are only
+// checked for localhost here; the addresses they resolve to are checked by
+// AllowsAddress when the dispatcher connects, since DNS may change after
+// the endpoint is registered.
+func (p WebhookURLPolicy) ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrInvalidWebhookURL
+	}
+
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		if !p.AllowInsecure {
+			return ErrInvalidWebhookURL
+		}
+	default:
+		return ErrInvalidWebhookURL
+	}
+
+	if p.AllowInsecure {
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidWebhookURL
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !p.AllowsAddress(addr) {
+		return ErrInvalidWebhookURL
+	}
+
+	return nil
+}
+
+// AllowsAddress reports whether the dispatcher may connect to addr.
+func (p WebhookURLPolicy) AllowsAddress(addr netip.Addr) bool {
+	if p.AllowInsecure {
+		return true
+	}
+
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckDial checks the resolved "host:port" address of a connection about
+// to be made, returning ErrWebhookAddressBlocked when it is not allowed.
+func (p WebhookURLPolicy) CheckDial(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !p.AllowsAddress(addr) {
+		return ErrWebhookAddressBlocked
+	}
+
+	return nil
+}
+
+func validateWebhookEventTypes(eventTypes []WebhookEventType) error {
+	if len(eventTypes) == 0 {
+		return ErrInvalidWebhookEventType
+	}
+
+	for _, eventType := range eventTypes {
+		if !supportedWebhookEvents[eventType] {
+			return ErrInvalidWebhookEventType
+		}
+	}
+
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
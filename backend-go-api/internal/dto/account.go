@@ -1,38 +1,65 @@
 package dto
 
 import (
+	"strings"
 	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 )
 
 type CreateAccountInput struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name  string `json:"name" validate:"required,max=255"`
+	Email string `json:"email" validate:"required,max=255,email"`
 }
 
+// UpdateAccountInput changes the fields that are set and leaves the others
+// unchanged.
+type UpdateAccountInput struct {
+	Name  *string `json:"name,omitempty" validate:"notblank,max=255"`
+	Email *string `json:"email,omitempty" validate:"notblank,max=255,email"`
+}
+
+type BalanceResponse struct {
+	Currency string       `json:"currency"`
+	Amount   domain.Money `json:"amount"`
+}
+
+// AccountResponse carries the api key only when the account was just
+// created; it cannot be read back afterwards.
 type AccountResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Balance   float64   `json:"balance"`
-	APIKey    string    `json:"api_key,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Email     string            `json:"email"`
+	Status    string            `json:"status"`
+	Balances  []BalanceResponse `json:"balances"`
+	APIKey    string            `json:"api_key,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	ClosedAt  *time.Time        `json:"closed_at,omitempty"`
 }
 
 func ToAccount(input *CreateAccountInput) *domain.Account {
-	return domain.NewAccount(input.Name, input.Email)
+	return domain.NewAccount(strings.TrimSpace(input.Name), strings.TrimSpace(input.Email))
 }
 
 func FromAccount(account *domain.Account) AccountResponse {
+	balances := make([]BalanceResponse, 0)
+	for _, balance := range account.Balances() {
+		balances = append(balances, BalanceResponse{
+			Currency: balance.Currency,
+			Amount:   balance,
+		})
+	}
+
 	return AccountResponse{
 		ID:        account.ID,
 		Name:      account.Name,
 		Email:     account.Email,
-		Balance:   account.Balance,
+		Status:    string(account.Status),
+		Balances:  balances,
 		APIKey:    account.APIKey,
 		CreatedAt: account.CreatedAt,
 		UpdatedAt: account.UpdatedAt,
+		ClosedAt:  account.ClosedAt,
 	}
 }
@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"strings"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type CreateAPIKeyInput struct {
+	Label string `json:"label"`
+}
+
+type RevokeAPIKeyInput struct {
+	// GracePeriod is a duration such as "24h" during which the key keeps
+	// working. Empty revokes the key immediately.
+	GracePeriod string `json:"grace_period,omitempty"`
+}
+
+type APIKeyResponse struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Prefix string `json:"prefix"`
+	// Key is only returned when the key is created.
+	Key        string     `json:"key,omitempty"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// ToGracePeriod parses the grace period of a revocation, zero when empty.
+func ToGracePeriod(input RevokeAPIKeyInput) (time.Duration, error) {
+	value := strings.TrimSpace(input.GracePeriod)
+	if value == "" {
+		return 0, nil
+	}
+
+	gracePeriod, err := time.ParseDuration(value)
+	if err != nil || gracePeriod < 0 {
+		return 0, domain.ErrInvalidGracePeriod
+	}
+
+	return gracePeriod, nil
+}
+
+func FromAPIKey(key *domain.APIKey, now time.Time) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Label:      key.Label,
+		Prefix:     key.Prefix,
+		Active:     key.IsActive(now),
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		ExpiresAt:  key.ExpiresAt,
+	}
+}
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type CreateCardTokenInput struct {
+	CardNumber     string `json:"card_number"`
+	CVV            string `json:"card_cvv"`
+	ExpiryMonth    int    `json:"expiry_month"`
+	ExpiryYear     int    `json:"expiry_year"`
+	CardholderName string `json:"cardholder_name"`
+}
+
+type CardTokenResponse struct {
+	Token          string    `json:"token"`
+	Brand          string    `json:"brand"`
+	Last4          string    `json:"last4"`
+	ExpiryMonth    int       `json:"expiry_month"`
+	ExpiryYear     int       `json:"expiry_year"`
+	CardholderName string    `json:"cardholder_name"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func ToTokenizedCard(input *CreateCardTokenInput) domain.CreditCard {
+	return domain.CreditCard{
+		Number:         input.CardNumber,
+		CVV:            input.CVV,
+		ExpiryMonth:    input.ExpiryMonth,
+		ExpiryYear:     input.ExpiryYear,
+		CardholderName: input.CardholderName,
+	}
+}
+
+func FromCardToken(token *domain.CardToken) *CardTokenResponse {
+	return &CardTokenResponse{
+		Token:          token.ID,
+		Brand:          string(token.Brand),
+		Last4:          token.LastDigits,
+		ExpiryMonth:    token.ExpiryMonth,
+		ExpiryYear:     token.ExpiryYear,
+		CardholderName: token.CardholderName,
+		CreatedAt:      token.CreatedAt,
+	}
+}
@@ -0,0 +1,157 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatCSV   ExportFormat = "csv"
+	ExportFormatJSONL ExportFormat = "jsonl"
+)
+
+var invoiceCSVHeader = []string{
+	"id", "account_id", "amount", "currency", "captured_amount", "refunded_amount", "status", "auto_capture",
+	"authorization_expires_at", "description", "payment_type", "card_last_digits", "card_brand", "created_at", "updated_at",
+}
+
+// ParseExportFormat defaults to csv when format is empty.
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(strings.TrimSpace(format))) {
+	case "", ExportFormatCSV:
+		return ExportFormatCSV, nil
+	case ExportFormatJSONL:
+		return ExportFormatJSONL, nil
+	default:
+		return "", domain.ErrUnsupportedExportFormat
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	if f == ExportFormatJSONL {
+		return "application/x-ndjson"
+	}
+
+	return "text/csv; charset=utf-8"
+}
+
+// InvoiceExportFilename names the export after the created_at range of the
+// filter. Open ends are written as "start" and the export date.
+func InvoiceExportFilename(filter domain.InvoiceFilter, format ExportFormat, now time.Time) string {
+	from := "start"
+	if filter.CreatedFrom != nil {
+		from = filter.CreatedFrom.UTC().Format(dateLayout)
+	}
+
+	to := now.UTC().Format(dateLayout)
+	if filter.CreatedTo != nil {
+		// CreatedTo is exclusive, so name the last day it covers
+		to = filter.CreatedTo.Add(-time.Nanosecond).UTC().Format(dateLayout)
+	}
+
+	return "invoices_" + from + "_" + to + "." + string(format)
+}
+
+// InvoiceExporter writes invoices one at a time in the chosen format. It
+// writes nothing until the first invoice or Close, so the response can
+// still turn into an error before that.
+type InvoiceExporter struct {
+	format  ExportFormat
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+}
+
+func NewInvoiceExporter(format ExportFormat, w io.Writer) *InvoiceExporter {
+	exporter := &InvoiceExporter{format: format}
+	if format == ExportFormatJSONL {
+		exporter.json = json.NewEncoder(w)
+	} else {
+		exporter.csv = csv.NewWriter(w)
+	}
+
+	return exporter
+}
+
+// Started reports whether anything was written yet.
+func (e *InvoiceExporter) Started() bool {
+	return e.started
+}
+
+func (e *InvoiceExporter) Write(invoice *InvoiceResponse) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+
+	if e.json != nil {
+		return e.json.Encode(invoice)
+	}
+
+	authorizationExpiresAt := ""
+	if invoice.AuthorizationExpiresAt != nil {
+		authorizationExpiresAt = invoice.AuthorizationExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return e.csv.Write([]string{
+		invoice.ID,
+		invoice.AccountID,
+		invoice.Amount.String(),
+		invoice.Currency,
+		invoice.CapturedAmount.String(),
+		invoice.RefundedAmount.String(),
+		invoice.Status,
+		strconv.FormatBool(invoice.AutoCapture),
+		authorizationExpiresAt,
+		escapeCSVFormula(invoice.Description),
+		invoice.PaymentType,
+		invoice.CardLastDigits,
+		invoice.CardBrand,
+		invoice.CreatedAt.UTC().Format(time.RFC3339),
+		invoice.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// Close writes the CSV header of an empty export and flushes buffered rows.
+func (e *InvoiceExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	return nil
+}
+
+func (e *InvoiceExporter) start() error {
+	if e.started {
+		return nil
+	}
+
+	e.started = true
+	if e.csv != nil {
+		return e.csv.Write(invoiceCSVHeader)
+	}
+
+	return nil
+}
+
+// escapeCSVFormula keeps spreadsheets from evaluating merchant supplied
+// text as a formula.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
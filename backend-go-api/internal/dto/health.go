@@ -0,0 +1,23 @@
+package dto
+
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// ReadinessResponse reports every dependency checked, keyed by name.
+type ReadinessResponse struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+type DependencyStatus struct {
+	Status    string         `json:"status"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+	LatencyMs int64          `json:"latency_ms"`
+}
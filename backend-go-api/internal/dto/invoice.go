@@ -1,6 +1,9 @@
 package dto
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
@@ -10,59 +13,204 @@ const (
 	StatusPending  = string(domain.StatusPending)
 	StatusApproved = string(domain.StatusApproved)
 	StatusRejected = string(domain.StatusRejected)
+
+	StatusPartiallyRefunded = string(domain.StatusPartiallyRefunded)
+	StatusRefunded          = string(domain.StatusRefunded)
+
+	StatusAuthorized = string(domain.StatusAuthorized)
+	StatusVoided     = string(domain.StatusVoided)
+	StatusExpired    = string(domain.StatusExpired)
 )
 
 type CreateInvoiceInput struct {
-	APIKey         string
-	Amount         float64 `json:"amount"`
-	Description    string  `json:"description"`
-	PaymentType    string  `json:"payment_type"`
-	CardNumber     string  `json:"card_number"`
-	CVV            string  `json:"card_cvv"`
-	ExpiryMonth    int     `json:"expiry_month"`
-	ExpiryYear     int     `json:"expiry_year"`
-	CardholderName string  `json:"cardholder_name"`
+	IdempotencyKey string       `json:"-"`
+	Amount         domain.Money `json:"amount" validate:"required,positive"`
+	Currency       string       `json:"currency" validate:"currency"`
+	Description    string       `json:"description"`
+	PaymentType    string       `json:"payment_type" validate:"required,oneof=credit_card"`
+	CardNumber     string       `json:"card_number"`
+	CVV            string       `json:"card_cvv"`
+	ExpiryMonth    int          `json:"expiry_month"`
+	ExpiryYear     int          `json:"expiry_year"`
+	CardholderName string       `json:"cardholder_name"`
+	// CardToken charges a card saved with POST /cards/tokens instead of the
+	// card fields above. card_cvv may still be sent along with it.
+	CardToken string `json:"card_token,omitempty"`
+	// Capture defaults to true. When false an approval only authorizes the
+	// amount, which must then be captured through the capture endpoint.
+	Capture *bool `json:"capture,omitempty"`
+}
+
+type CaptureInvoiceInput struct {
+	// Amount is optional; when omitted the full authorized amount is captured.
+	Amount *domain.Money `json:"amount,omitempty"`
+	// Currency is optional. When sent it must be the currency of the
+	// invoice, since captures are never converted.
+	Currency string `json:"currency,omitempty"`
 }
 
 type InvoiceResponse struct {
-	ID             string    `json:"id"`
-	AccountID      string    `json:"account_id"`
-	Amount         float64   `json:"amount"`
-	Status         string    `json:"status"`
-	Description    string    `json:"description"`
-	PaymentType    string    `json:"payment_type"`
-	CardLastDigits string    `json:"card_last_digits"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID                     string       `json:"id"`
+	AccountID              string       `json:"account_id"`
+	Amount                 domain.Money `json:"amount"`
+	Currency               string       `json:"currency"`
+	CapturedAmount         domain.Money `json:"captured_amount"`
+	RefundedAmount         domain.Money `json:"refunded_amount"`
+	Status                 string       `json:"status"`
+	AutoCapture            bool         `json:"auto_capture"`
+	AuthorizationExpiresAt *time.Time   `json:"authorization_expires_at,omitempty"`
+	Description            string       `json:"description"`
+	PaymentType            string       `json:"payment_type"`
+	CardLastDigits         string       `json:"card_last_digits"`
+	CardBrand              string       `json:"card_brand"`
+	CreatedAt              time.Time    `json:"created_at"`
+	UpdatedAt              time.Time    `json:"updated_at"`
+	// Replayed is set when the response was served from a stored
+	// Idempotency-Key result instead of creating a new invoice.
+	Replayed bool `json:"-"`
 }
 
-func ToInvoice(input *CreateInvoiceInput, accountID string) (*domain.Invoice, error) {
-	card := domain.CreditCard{
+func ToCreditCard(input *CreateInvoiceInput) domain.CreditCard {
+	return domain.CreditCard{
 		Number:         input.CardNumber,
 		CVV:            input.CVV,
 		ExpiryMonth:    input.ExpiryMonth,
 		ExpiryYear:     input.ExpiryYear,
 		CardholderName: input.CardholderName,
 	}
+}
+
+func ToInvoice(input *CreateInvoiceInput, accountID string, card domain.CreditCard) (*domain.Invoice, error) {
+	amount := input.Amount
+	amount.Currency = domain.NormalizeCurrency(input.Currency)
 
 	return domain.NewInvoice(
 		accountID,
-		input.Amount,
+		amount,
 		input.Description,
 		input.PaymentType,
 		card,
+		input.Capture == nil || *input.Capture,
 	)
 }
 
+// ToCaptureAmount is the amount to capture from invoice: the requested one,
+// or the full authorized amount when none was given.
+func ToCaptureAmount(input CaptureInvoiceInput, invoice *domain.Invoice) (domain.Money, error) {
+	return toInvoiceAmount(input.Amount, input.Currency, invoice, invoice.Amount)
+}
+
+// toInvoiceAmount reads an amount requested on an existing invoice in the
+// currency of the invoice, or returns fallback when amount is nil. Any
+// other currency fails with ErrUnsupportedCurrency.
+func toInvoiceAmount(amount *domain.Money, currency string, invoice *domain.Invoice, fallback domain.Money) (domain.Money, error) {
+	if strings.TrimSpace(currency) != "" && domain.NormalizeCurrency(currency) != invoice.Amount.Currency {
+		return domain.Money{}, fmt.Errorf("%w: amounts on this invoice must be in %s", domain.ErrUnsupportedCurrency, invoice.Amount.Currency)
+	}
+
+	if amount == nil {
+		return fallback, nil
+	}
+
+	return domain.NewMoney(amount.Amount, invoice.Amount.Currency), nil
+}
+
 func FromInvoice(invoice *domain.Invoice) *InvoiceResponse {
 	return &InvoiceResponse{
-		ID:             invoice.ID,
-		Amount:         invoice.Amount,
-		Status:         string(invoice.Status),
-		Description:    invoice.Description,
-		PaymentType:    invoice.PaymentType,
-		CardLastDigits: invoice.CardLastDigits,
-		CreatedAt:      invoice.CreatedAt,
-		UpdatedAt:      invoice.UpdatedAt,
+		ID:                     invoice.ID,
+		AccountID:              invoice.AccountID,
+		Amount:                 invoice.Amount,
+		Currency:               invoice.Amount.Currency,
+		CapturedAmount:         invoice.CapturedAmount,
+		RefundedAmount:         invoice.RefundedAmount,
+		Status:                 string(invoice.Status),
+		AutoCapture:            invoice.AutoCapture,
+		AuthorizationExpiresAt: invoice.AuthorizationExpiresAt,
+		Description:            invoice.Description,
+		PaymentType:            invoice.PaymentType,
+		CardLastDigits:         invoice.CardLastDigits,
+		CardBrand:              string(invoice.CardBrand),
+		CreatedAt:              invoice.CreatedAt,
+		UpdatedAt:              invoice.UpdatedAt,
+	}
+}
+
+// dateLayout is accepted by the created_from and created_to filters along
+// with RFC 3339 timestamps.
+const dateLayout = "2006-01-02"
+
+// ToInvoiceFilter reads the listing filters from the query string: status
+// (comma separated), payment_type, currency, min_amount, max_amount,
+// created_from, created_to and sort. A created_to date without time covers
+// that whole day.
+func ToInvoiceFilter(query url.Values) (domain.InvoiceFilter, error) {
+	filter := domain.InvoiceFilter{
+		PaymentType: strings.TrimSpace(query.Get("payment_type")),
+		Sort:        domain.InvoiceSort(strings.TrimSpace(query.Get("sort"))),
+	}
+
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			filter.Statuses = append(filter.Statuses, domain.Status(status))
+		}
 	}
+
+	if currency := strings.TrimSpace(query.Get("currency")); currency != "" {
+		filter.Currency = domain.NormalizeCurrency(currency)
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **domain.Money
+	}{
+		{"min_amount", &filter.MinAmount},
+		{"max_amount", &filter.MaxAmount},
+	} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+
+		amount, err := domain.ParseMoney(value, filter.Currency)
+		if err != nil {
+			return domain.InvoiceFilter{}, fmt.Errorf("%w: %s must be a decimal amount", domain.ErrInvalidInvoiceFilter, bound.name)
+		}
+		*bound.target = &amount
+	}
+
+	createdFrom, _, err := parseFilterTime(query, "created_from")
+	if err != nil {
+		return domain.InvoiceFilter{}, err
+	}
+	filter.CreatedFrom = createdFrom
+
+	createdTo, dateOnly, err := parseFilterTime(query, "created_to")
+	if err != nil {
+		return domain.InvoiceFilter{}, err
+	}
+	if createdTo != nil && dateOnly {
+		endOfDay := createdTo.AddDate(0, 0, 1)
+		createdTo = &endOfDay
+	}
+	filter.CreatedTo = createdTo
+
+	return filter, nil
+}
+
+func parseFilterTime(query url.Values, name string) (*time.Time, bool, error) {
+	value := strings.TrimSpace(query.Get(name))
+	if value == "" {
+		return nil, false, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, false, nil
+	}
+
+	parsed, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s must be an RFC 3339 timestamp or a YYYY-MM-DD date", domain.ErrInvalidInvoiceFilter, name)
+	}
+
+	return &parsed, true, nil
 }
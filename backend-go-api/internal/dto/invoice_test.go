@@ -0,0 +1,33 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+func TestToCaptureAmount(t *testing.T) {
+	invoice := &domain.Invoice{Amount: domain.NewMoney(10000, domain.CurrencyEUR)}
+	requested := domain.NewMoney(8000, "")
+
+	tests := []struct {
+		name    string
+		input   CaptureInvoiceInput
+		want    domain.Money
+		wantErr error
+	}{
+		{"authorized amount", CaptureInvoiceInput{}, domain.NewMoney(10000, domain.CurrencyEUR), nil},
+		{"requested amount", CaptureInvoiceInput{Amount: &requested}, domain.NewMoney(8000, domain.CurrencyEUR), nil},
+		{"invoice currency", CaptureInvoiceInput{Amount: &requested, Currency: " eur "}, domain.NewMoney(8000, domain.CurrencyEUR), nil},
+		{"other currency", CaptureInvoiceInput{Amount: &requested, Currency: domain.CurrencyUSD}, domain.Money{}, domain.ErrUnsupportedCurrency},
+		{"other currency without amount", CaptureInvoiceInput{Currency: domain.CurrencyBRL}, domain.Money{}, domain.ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := ToCaptureAmount(tt.input, invoice)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("%s: ToCaptureAmount() = %v %s, %v, want %v %s, %v", tt.name, got, got.Currency, err, tt.want, tt.want.Currency, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type LedgerEntryResponse struct {
+	ID            string       `json:"id"`
+	TransactionID string       `json:"transaction_id"`
+	LedgerAccount string       `json:"ledger_account"`
+	Direction     string       `json:"direction"`
+	Amount        domain.Money `json:"amount"`
+	Currency      string       `json:"currency"`
+	Type          string       `json:"type"`
+	ReferenceID   string       `json:"reference_id,omitempty"`
+	Description   string       `json:"description"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// BalanceReconciliation compares, for one currency, the cached account
+// balance with the balance derived from the ledger.
+type BalanceReconciliation struct {
+	Currency      string       `json:"currency"`
+	LedgerBalance domain.Money `json:"ledger_balance"`
+	CachedBalance domain.Money `json:"cached_balance"`
+	InSync        bool         `json:"in_sync"`
+}
+
+func FromLedgerEntry(entry *domain.LedgerEntry) LedgerEntryResponse {
+	return LedgerEntryResponse{
+		ID:            entry.ID,
+		TransactionID: entry.TransactionID,
+		LedgerAccount: string(entry.LedgerAccount),
+		Direction:     string(entry.Direction),
+		Amount:        entry.Amount,
+		Currency:      entry.Amount.Currency,
+		Type:          string(entry.Type),
+		ReferenceID:   entry.ReferenceID,
+		Description:   entry.Description,
+		CreatedAt:     entry.CreatedAt,
+	}
+}
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// Page wraps a list response with the cursor for the next page. NextCursor
+// is empty on the last page.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// EncodeCursor turns a cursor into the opaque token handed to clients.
+func EncodeCursor(cursor domain.Cursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a token produced by EncodeCursor. An empty token means
+// the first page and yields a nil cursor.
+func DecodeCursor(token string) (*domain.Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	return &domain.Cursor{CreatedAt: parsed, ID: id}, nil
+}
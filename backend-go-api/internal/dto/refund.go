@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type CreateRefundInput struct {
+	// Amount is optional; when omitted the remaining refundable amount of
+	// the invoice is refunded.
+	Amount *domain.Money `json:"amount,omitempty"`
+	// Currency is optional. When sent it must be the currency of the
+	// invoice, since refunds are never converted.
+	Currency string `json:"currency,omitempty"`
+}
+
+// ToRefundAmount is the amount to refund from invoice: the requested one,
+// or the remaining refundable amount when none was given.
+func ToRefundAmount(input CreateRefundInput, invoice *domain.Invoice) (domain.Money, error) {
+	return toInvoiceAmount(input.Amount, input.Currency, invoice, invoice.RefundableAmount())
+}
+
+type RefundResponse struct {
+	ID             string       `json:"id"`
+	InvoiceID      string       `json:"invoice_id"`
+	Amount         domain.Money `json:"amount"`
+	Currency       string       `json:"currency"`
+	InvoiceStatus  string       `json:"invoice_status"`
+	RefundedAmount domain.Money `json:"refunded_amount"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+func FromRefund(refund *domain.Refund, invoice *domain.Invoice) *RefundResponse {
+	return &RefundResponse{
+		ID:             refund.ID,
+		InvoiceID:      refund.InvoiceID,
+		Amount:         refund.Amount,
+		Currency:       refund.Amount.Currency,
+		InvoiceStatus:  string(invoice.Status),
+		RefundedAmount: invoice.RefundedAmount,
+		CreatedAt:      refund.CreatedAt,
+	}
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+func TestToRefundAmount(t *testing.T) {
+	invoice := &domain.Invoice{
+		Amount:         domain.NewMoney(10000, domain.CurrencyUSD),
+		CapturedAmount: domain.NewMoney(10000, domain.CurrencyUSD),
+		RefundedAmount: domain.NewMoney(2500, domain.CurrencyUSD),
+	}
+	requested := domain.NewMoney(1000, "")
+
+	tests := []struct {
+		name    string
+		input   CreateRefundInput
+		want    domain.Money
+		wantErr error
+	}{
+		{"remaining amount", CreateRefundInput{}, domain.NewMoney(7500, domain.CurrencyUSD), nil},
+		{"requested amount", CreateRefundInput{Amount: &requested}, domain.NewMoney(1000, domain.CurrencyUSD), nil},
+		{"invoice currency", CreateRefundInput{Amount: &requested, Currency: "usd"}, domain.NewMoney(1000, domain.CurrencyUSD), nil},
+		{"other currency", CreateRefundInput{Amount: &requested, Currency: domain.CurrencyBRL}, domain.Money{}, domain.ErrUnsupportedCurrency},
+		{"other currency without amount", CreateRefundInput{Currency: domain.CurrencyEUR}, domain.Money{}, domain.ErrUnsupportedCurrency},
+		{"unknown currency", CreateRefundInput{Amount: &requested, Currency: "JPY"}, domain.Money{}, domain.ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := ToRefundAmount(tt.input, invoice)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("%s: ToRefundAmount() = %v %s, %v, want %v %s, %v", tt.name, got, got.Currency, err, tt.want, tt.want.Currency, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package dto
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// Validate checks input, a pointer to a struct, against the comma separated
+// rules in the `validate` tags of its fields:
+//
+//	required     the field is set: non-blank strings, non-zero values
+//	notblank     a field that is set, such as a non-nil pointer, is not blank
+//	max=N        strings hold at most N characters
+//	email        strings are a bare address, see domain.ValidateEmail
+//	oneof=a b c  strings are one of the space separated values
+//	currency     strings are a supported currency code, in any case
+//	positive     numbers, or types with an IsPositive method, are above 0
+//
+// Rules other than required skip fields that are not set. Every failing
+// field is reported, in declaration order, by a *domain.ValidationError.
+func Validate(input any) error {
+	value := reflect.Indirect(reflect.ValueOf(input))
+	if value.Kind() != reflect.Struct {
+		panic("dto: Validate needs a pointer to a struct")
+	}
+
+	var fields []domain.FieldError
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		if fieldErr, ok := validateField(jsonName(field), value.Field(i), tag); !ok {
+			fields = append(fields, fieldErr)
+		}
+	}
+
+	if len(fields) > 0 {
+		return &domain.ValidationError{Fields: fields}
+	}
+
+	return nil
+}
+
+// validateField applies the rules of tag in order and reports the first one
+// the value breaks.
+func validateField(name string, value reflect.Value, tag string) (domain.FieldError, bool) {
+	set := isSet(value)
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		if rule == "required" {
+			if !set {
+				return domain.FieldError{Field: name, Code: "required", Message: "is required"}, false
+			}
+			continue
+		}
+		if !set {
+			continue
+		}
+
+		text := strings.TrimSpace(fmt.Sprint(reflect.Indirect(value).Interface()))
+
+		switch rule {
+		case "notblank":
+			if text == "" {
+				return domain.FieldError{Field: name, Code: "blank", Message: "must not be blank"}, false
+			}
+		case "max":
+			limit, err := strconv.Atoi(param)
+			if err != nil {
+				panic("dto: invalid max rule " + strconv.Quote(param))
+			}
+			if utf8.RuneCountInString(text) > limit {
+				return domain.FieldError{Field: name, Code: "too_long", Message: fmt.Sprintf("must be at most %d characters", limit)}, false
+			}
+		case "email":
+			if _, err := domain.ValidateEmail(text); err != nil {
+				return domain.FieldError{Field: name, Code: "invalid_email", Message: "must be an address such as user@example.com"}, false
+			}
+		case "oneof":
+			allowed := strings.Fields(param)
+			if !slices.Contains(allowed, text) {
+				return domain.FieldError{Field: name, Code: "not_allowed", Message: "must be one of " + strings.Join(allowed, ", ")}, false
+			}
+		case "currency":
+			if domain.ValidateCurrency(domain.NormalizeCurrency(text)) != nil {
+				return domain.FieldError{Field: name, Code: "unsupported_currency", Message: "must be one of " + strings.Join(domain.SupportedCurrencies(), ", ")}, false
+			}
+		case "positive":
+			if !isPositive(reflect.Indirect(value)) {
+				return domain.FieldError{Field: name, Code: "not_positive", Message: "must be greater than 0"}, false
+			}
+		default:
+			panic("dto: unknown validation rule " + strconv.Quote(rule))
+		}
+	}
+
+	return domain.FieldError{}, true
+}
+
+// isSet reports whether a field was given a value. Types with an IsZero
+// method, such as domain.Money, decide for themselves.
+func isSet(value reflect.Value) bool {
+	if value.Kind() == reflect.Pointer {
+		return !value.IsNil()
+	}
+	if zeroer, ok := value.Interface().(interface{ IsZero() bool }); ok {
+		return !zeroer.IsZero()
+	}
+	if value.Kind() == reflect.String {
+		return strings.TrimSpace(value.String()) != ""
+	}
+
+	return !value.IsZero()
+}
+
+// isPositive reports whether value is above zero. Types with an IsPositive
+// method, such as domain.Money, decide for themselves.
+func isPositive(value reflect.Value) bool {
+	if positive, ok := value.Interface().(interface{ IsPositive() bool }); ok {
+		return positive.IsPositive()
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() > 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() > 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() > 0
+	}
+
+	panic("dto: positive rule on a field of type " + value.Type().String())
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
@@ -0,0 +1,133 @@
+package dto
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// fieldErrors runs Validate and returns "field:code" for every rejected
+// field, in order.
+func fieldErrors(t *testing.T, input any) []string {
+	t.Helper()
+
+	err := Validate(input)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate() = %v, want a *domain.ValidationError", err)
+	}
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Validate() = %v, does not match ErrInvalidInput", err)
+	}
+
+	var fields []string
+	for _, field := range validationErr.Fields {
+		fields = append(fields, field.Field+":"+field.Code)
+	}
+
+	return fields
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestValidateCreateAccountInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input CreateAccountInput
+		want  []string
+	}{
+		{"valid", CreateAccountInput{Name: "Loja", Email: "loja@example.com"}, nil},
+		{"missing fields", CreateAccountInput{}, []string{"name:required", "email:required"}},
+		{"blank name", CreateAccountInput{Name: "   ", Email: "loja@example.com"}, []string{"name:required"}},
+		{"name too long", CreateAccountInput{Name: strings.Repeat("é", 256), Email: "loja@example.com"}, []string{"name:too_long"}},
+		{"name at the limit", CreateAccountInput{Name: strings.Repeat("é", 255), Email: "loja@example.com"}, nil},
+		{"invalid email", CreateAccountInput{Name: "Loja", Email: "loja"}, []string{"email:invalid_email"}},
+		{"email with display name", CreateAccountInput{Name: "Loja", Email: "Loja <loja@example.com>"}, []string{"email:invalid_email"}},
+	}
+
+	for _, tt := range tests {
+		if got := fieldErrors(t, &tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUpdateAccountInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input UpdateAccountInput
+		want  []string
+	}{
+		{"nothing to change", UpdateAccountInput{}, nil},
+		{"name only", UpdateAccountInput{Name: stringPointer("Loja")}, nil},
+		{"email only", UpdateAccountInput{Email: stringPointer("loja@example.com")}, nil},
+		{"blank name", UpdateAccountInput{Name: stringPointer(" ")}, []string{"name:blank"}},
+		{"empty email", UpdateAccountInput{Email: stringPointer("")}, []string{"email:blank"}},
+		{"name too long", UpdateAccountInput{Name: stringPointer(strings.Repeat("a", 256))}, []string{"name:too_long"}},
+		{"invalid email", UpdateAccountInput{Name: stringPointer("Loja"), Email: stringPointer("loja@")}, []string{"email:invalid_email"}},
+	}
+
+	for _, tt := range tests {
+		if got := fieldErrors(t, &tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCreateInvoiceInput(t *testing.T) {
+	valid := CreateInvoiceInput{Amount: domain.NewMoney(1000, ""), PaymentType: "credit_card"}
+	invoice := func(change func(*CreateInvoiceInput)) CreateInvoiceInput {
+		input := valid
+		change(&input)
+		return input
+	}
+
+	tests := []struct {
+		name  string
+		input CreateInvoiceInput
+		want  []string
+	}{
+		{"valid", valid, nil},
+		{"default currency", invoice(func(i *CreateInvoiceInput) { i.Currency = "" }), nil},
+		{"lower case currency", invoice(func(i *CreateInvoiceInput) { i.Currency = "usd" }), nil},
+		{"unsupported currency", invoice(func(i *CreateInvoiceInput) { i.Currency = "JPY" }), []string{"currency:unsupported_currency"}},
+		{"zero amount", invoice(func(i *CreateInvoiceInput) { i.Amount = domain.NewMoney(0, "") }), []string{"amount:required"}},
+		{"negative amount", invoice(func(i *CreateInvoiceInput) { i.Amount = domain.NewMoney(-100, "") }), []string{"amount:not_positive"}},
+		{"unknown payment type", invoice(func(i *CreateInvoiceInput) { i.PaymentType = "pix" }), []string{"payment_type:not_allowed"}},
+		{"every field", CreateInvoiceInput{Currency: "JPY"}, []string{"amount:required", "currency:unsupported_currency", "payment_type:required"}},
+	}
+
+	for _, tt := range tests {
+		if got := fieldErrors(t, &tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	err := Validate(&CreateInvoiceInput{Amount: domain.NewMoney(100, ""), PaymentType: "credit_card", Currency: "JPY"})
+	if err == nil || err.Error() != "currency: must be one of BRL, EUR, USD" {
+		t.Errorf("Validate() = %v, want the supported currencies listed", err)
+	}
+}
+
+func TestValidatePanicsOnUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Validate() did not panic on an unknown rule")
+		}
+	}()
+
+	Validate(&struct {
+		Name string `validate:"shiny"`
+	}{Name: "x"})
+}
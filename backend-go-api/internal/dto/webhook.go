@@ -0,0 +1,110 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type CreateWebhookEndpointInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type WebhookEndpointResponse struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     bool     `json:"active"`
+	// Secret is only returned when the endpoint is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookEvent is the body posted to webhook endpoints.
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      *InvoiceResponse `json:"data"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID            string                           `json:"id"`
+	EndpointID    string                           `json:"endpoint_id"`
+	EventType     string                           `json:"event_type"`
+	Status        string                           `json:"status"`
+	Attempts      int                              `json:"attempts"`
+	LastError     string                           `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time                       `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time                        `json:"created_at"`
+	DeliveredAt   *time.Time                       `json:"delivered_at,omitempty"`
+	Payload       json.RawMessage                  `json:"payload,omitempty"`
+	AttemptLog    []WebhookDeliveryAttemptResponse `json:"attempt_log,omitempty"`
+}
+
+type WebhookDeliveryAttemptResponse struct {
+	ID             string    `json:"id"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	ResponseBody   string    `json:"response_body,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+func ToWebhookEventTypes(eventTypes []string) []domain.WebhookEventType {
+	result := make([]domain.WebhookEventType, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		result = append(result, domain.WebhookEventType(eventType))
+	}
+
+	return result
+}
+
+func FromWebhookEndpoint(endpoint *domain.WebhookEndpoint) WebhookEndpointResponse {
+	eventTypes := make([]string, 0, len(endpoint.EventTypes))
+	for _, eventType := range endpoint.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+
+	return WebhookEndpointResponse{
+		ID:         endpoint.ID,
+		URL:        endpoint.URL,
+		EventTypes: eventTypes,
+		Active:     endpoint.Active,
+		CreatedAt:  endpoint.CreatedAt,
+		UpdatedAt:  endpoint.UpdatedAt,
+	}
+}
+
+func FromWebhookDelivery(delivery *domain.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:          delivery.ID,
+		EndpointID:  delivery.EndpointID,
+		EventType:   string(delivery.EventType),
+		Status:      string(delivery.Status),
+		Attempts:    delivery.Attempts,
+		LastError:   delivery.LastError,
+		CreatedAt:   delivery.CreatedAt,
+		DeliveredAt: delivery.DeliveredAt,
+	}
+
+	if delivery.Status == domain.WebhookDeliveryPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+
+	return response
+}
+
+func FromWebhookDeliveryAttempt(attempt *domain.WebhookDeliveryAttempt) WebhookDeliveryAttemptResponse {
+	return WebhookDeliveryAttemptResponse{
+		ID:             attempt.ID,
+		ResponseStatus: attempt.ResponseStatus,
+		ResponseBody:   attempt.ResponseBody,
+		Error:          attempt.Error,
+		DurationMs:     attempt.Duration.Milliseconds(),
+		AttemptedAt:    attempt.AttemptedAt,
+	}
+}
@@ -0,0 +1,134 @@
+// Package metrics holds the Prometheus collectors exposed on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "gateway"
+
+// Stages at which consuming a Kafka message can fail.
+const (
+	ConsumeStageFetch   = "fetch"
+	ConsumeStageProcess = "process"
+	ConsumeStageCommit  = "commit"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	invoices = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "invoices_total",
+		Help:      "Invoices that reached each status, by payment type.",
+	}, []string{"status", "payment_type"})
+
+	invoiceResultLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "invoice_result_latency_seconds",
+		Help:      "Time from the creation of a pending invoice to the final status set by the fraud analysis.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"status"})
+
+	kafkaMessagesProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_produced_total",
+		Help:      "Messages written to Kafka by topic.",
+	}, []string{"topic"})
+
+	kafkaProduceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "produce_errors_total",
+		Help:      "Failed Kafka writes by topic.",
+	}, []string{"topic"})
+
+	kafkaMessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Messages read from Kafka by topic.",
+	}, []string{"topic"})
+
+	kafkaConsumeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consume_errors_total",
+		Help:      "Kafka consumer failures by topic and stage (fetch, process or commit).",
+	}, []string{"topic", "stage"})
+)
+
+// ObserveHTTPRequest records a served request. route is the chi route
+// pattern, so path parameters do not create new series.
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// RecordInvoice counts the invoice in its current status. It must be
+// called once the change was committed.
+func RecordInvoice(invoice *domain.Invoice) {
+	invoices.WithLabelValues(string(invoice.Status), invoice.PaymentType).Inc()
+}
+
+// ObserveInvoiceResult records how long a pending invoice took to get its
+// final status.
+func ObserveInvoiceResult(invoice *domain.Invoice, now time.Time) {
+	invoiceResultLatency.WithLabelValues(string(invoice.Status)).Observe(now.Sub(invoice.CreatedAt).Seconds())
+}
+
+// RecordKafkaProduce counts a write to topic, successful when err is nil.
+func RecordKafkaProduce(topic string, err error) {
+	if err != nil {
+		kafkaProduceErrors.WithLabelValues(topic).Inc()
+		return
+	}
+
+	kafkaMessagesProduced.WithLabelValues(topic).Inc()
+}
+
+func RecordKafkaConsume(topic string) {
+	kafkaMessagesConsumed.WithLabelValues(topic).Inc()
+}
+
+func RecordKafkaConsumeError(topic, stage string) {
+	kafkaConsumeErrors.WithLabelValues(topic, stage).Inc()
+}
+
+// RegisterConsumerLag exports the lag reported by lag, read on every
+// scrape.
+func RegisterConsumerLag(topic, groupID string, lag func() int64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "kafka",
+		Name:        "consumer_lag",
+		Help:        "Messages the consumer is behind the end of its partition.",
+		ConstLabels: prometheus.Labels{"topic": topic, "group_id": groupID},
+	}, func() float64 { return float64(lag()) })
+}
+
+// RegisterDBStats exports the connection pool stats of db.
+func RegisterDBStats(db *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
@@ -1,148 +1,227 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log" // Added for logging
 	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/lib/pq"
 )
 
+// foreignKeyViolation is the Postgres error code raised when a referenced
+// row does not exist.
+const foreignKeyViolation = "23503"
+
+// accountsEmailConstraint is the unique constraint on accounts.email.
+const accountsEmailConstraint = "accounts_email_key"
+
+const accountColumns = `id, name, email, status, created_at, updated_at, closed_at`
+
 type AccountRepository struct {
-	db *sql.DB
+	db DBTX
+}
+
+func NewAccountRepository(db DBTX) *AccountRepository {
+	return &AccountRepository{db: traceDB(db)}
+}
+
+// CreateAccount stores the account along with account.APIKey as its first
+// api key.
+func (r *AccountRepository) CreateAccount(ctx context.Context, account *domain.Account) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO accounts (id, name, email, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)
+		`)
+
+		if err != nil {
+			log.Printf("ERROR preparing insert statement: %v", err) // Added log
+			return err
+		}
+
+		defer stmt.Close()
+
+		_, err = stmt.ExecContext(
+			ctx,
+			account.ID,
+			account.Name,
+			account.Email,
+			account.Status,
+			account.CreatedAt,
+			account.UpdatedAt,
+		)
+
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == accountsEmailConstraint {
+				return domain.ErrDuplicateEmail
+			}
+			log.Printf("ERROR executing insert statement: %v", err) // Added log
+			return err
+		}
+
+		return NewAPIKeyRepository(tx).Create(ctx, domain.InitialAPIKey(account))
+	})
 }
 
-func NewAccountRepository(db *sql.DB) *AccountRepository {
-	return &AccountRepository{db: db}
+func (r *AccountRepository) FindByID(ctx context.Context, id string) (*domain.Account, error) {
+	return r.findAccount(ctx, `
+		SELECT `+accountColumns+`
+		FROM accounts
+		WHERE id = $1
+	`, id)
 }
 
-func (r *AccountRepository) CreateAccount(account *domain.Account) error {
-	stmt, err := r.db.Prepare(`
-		INSERT INTO accounts (id, name, email, api_key, balance, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`)
+// FindByIDForUpdate locks the account row for the rest of the transaction.
+func (r *AccountRepository) FindByIDForUpdate(ctx context.Context, id string) (*domain.Account, error) {
+	return r.findAccount(ctx, `
+		SELECT `+accountColumns+`
+		FROM accounts
+		WHERE id = $1
+		FOR UPDATE
+	`, id)
+}
 
+func (r *AccountRepository) findAccount(ctx context.Context, query string, args ...any) (*domain.Account, error) {
+	account, err := scanAccount(r.db.QueryRowContext(ctx, query, args...))
 	if err != nil {
-		log.Printf("ERROR preparing insert statement: %v", err) // Added log
-		return err
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAccountNotFound
+		}
+		return nil, err
 	}
 
-	defer stmt.Close()
+	if err := r.loadBalances(ctx, account); err != nil {
+		return nil, err
+	}
 
-	_, err = stmt.Exec(
-		account.ID,
-		account.Name,
-		account.Email,
-		account.APIKey,
-		account.Balance,
-		account.CreatedAt,
-		account.UpdatedAt,
-	)
+	return account, nil
+}
+
+// Update stores the name, email and status of the account.
+func (r *AccountRepository) Update(ctx context.Context, account *domain.Account) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE accounts
+		SET name = $1, email = $2, status = $3, updated_at = $4, closed_at = $5
+		WHERE id = $6
+	`, account.Name, account.Email, account.Status, account.UpdatedAt, account.ClosedAt, account.ID)
 
 	if err != nil {
-		log.Printf("ERROR executing insert statement: %v", err) // Added log
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == accountsEmailConstraint {
+			return domain.ErrDuplicateEmail
+		}
 		return err
 	}
 
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrAccountNotFound
+	}
+
 	return nil
 }
 
-func (r *AccountRepository) FindByAPIKey(apiKey string) (*domain.Account, error) {
-	var account domain.Account
-	var createdAt, updatedAt time.Time
+func (r *AccountRepository) AddBalance(ctx context.Context, accountID string, amount domain.Money) (domain.Money, error) {
+	if amount.IsNegative() {
+		return r.debitBalance(ctx, accountID, amount)
+	}
 
-	err := r.db.QueryRow(`
-		SELECT id, name, email, api_key, balance, created_at, updated_at
-		FROM accounts
-		WHERE api_key = $1
-	`, apiKey).Scan(
-		&account.ID,
-		&account.Name,
-		&account.Email,
-		&account.APIKey,
-		&account.Balance,
-		&createdAt,
-		&updatedAt,
-	)
+	balance := domain.NewMoney(0, amount.Currency)
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO account_balances (account_id, currency, balance, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id, currency) DO UPDATE
+		SET balance = account_balances.balance + EXCLUDED.balance, updated_at = EXCLUDED.updated_at
+		RETURNING balance
+	`, accountID, amount.Currency, amount, time.Now()).Scan(&balance)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, domain.ErrAccountNotFound
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return domain.Money{}, domain.ErrAccountNotFound
 		}
-		return nil, err
+		return domain.Money{}, err
 	}
 
-	account.CreatedAt = createdAt
-	account.UpdatedAt = updatedAt
-
-	return &account, nil
+	return balance, nil
 }
 
-func (r *AccountRepository) FindByID(id string) (*domain.Account, error) {
-	var account domain.Account
-	var createdAt, updatedAt time.Time
+// debitBalance subtracts from an existing balance only when enough funds
+// are available, so concurrent debits can never overdraw the account.
+func (r *AccountRepository) debitBalance(ctx context.Context, accountID string, amount domain.Money) (domain.Money, error) {
+	balance := domain.NewMoney(0, amount.Currency)
 
-	err := r.db.QueryRow(`
-		SELECT id, name, email, api_key, balance, created_at, updated_at
-		FROM accounts 
-		WHERE id = $1
-	`, id).Scan(
-		&account.ID,
-		&account.Name,
-		&account.Email,
-		&account.APIKey,
-		&account.Balance,
-		&createdAt,
-		&updatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE account_balances
+		SET balance = balance + $1, updated_at = $2
+		WHERE account_id = $3 AND currency = $4 AND balance + $1 >= 0
+		RETURNING balance
+	`, amount, time.Now(), accountID, amount.Currency).Scan(&balance)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, domain.ErrAccountNotFound
+			return domain.Money{}, domain.ErrInsufficientBalance
 		}
-		return nil, err
+		return domain.Money{}, err
 	}
 
-	account.CreatedAt = createdAt
-	account.UpdatedAt = updatedAt
-
-	return &account, nil
+	return balance, nil
 }
 
-func (r *AccountRepository) UpdateBalance(account *domain.Account) error {
-	tx, err := r.db.Begin()
+func (r *AccountRepository) loadBalances(ctx context.Context, account *domain.Account) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT currency, balance
+		FROM account_balances
+		WHERE account_id = $1
+	`, account.ID)
+
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback()
-
-	var currentBalance float64
+	defer rows.Close()
 
-	err = tx.QueryRow(
-		`SELECT balance 
-		 FROM accounts 
-		 WHERE id = $1 
-		 FOR UPDATE
-		`, account.ID).Scan(&currentBalance)
+	for rows.Next() {
+		var currency string
+		var balance domain.Money
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return domain.ErrAccountNotFound
+		if err := rows.Scan(&currency, &balance); err != nil {
+			return err
 		}
-		return err
+
+		balance.Currency = currency
+		account.SetBalance(balance)
 	}
 
-	account.UpdatedAt = time.Now()
+	return rows.Err()
+}
 
-	_, err = tx.Exec(`
-		UPDATE accounts 
-		SET balance = $1, updated_at = $2 
-		WHERE id = $3
-	`, account.Balance, account.UpdatedAt, account.ID)
+func scanAccount(row rowScanner) (*domain.Account, error) {
+	var account domain.Account
+	var closedAt sql.NullTime
 
-	if err != nil {
-		return err
+	if err := row.Scan(
+		&account.ID,
+		&account.Name,
+		&account.Email,
+		&account.Status,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&closedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if closedAt.Valid {
+		account.ClosedAt = &closedAt.Time
 	}
 
-	return tx.Commit()
+	return &account, nil
 }
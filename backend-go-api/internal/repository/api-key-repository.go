@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres error code raised when a unique
+// constraint is violated.
+const uniqueViolation = "23505"
+
+const apiKeyColumns = `id, account_id, key_prefix, key_hash, label, created_at, last_used_at, revoked_at, expires_at`
+
+type APIKeyRepository struct {
+	db DBTX
+}
+
+func NewAPIKeyRepository(db DBTX) *APIKeyRepository {
+	return &APIKeyRepository{db: traceDB(db)}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, account_id, key_prefix, key_hash, label, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, key.ID, key.AccountID, key.Prefix, key.Hash, key.Label, key.CreatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return domain.ErrDuplicateAPIKey
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FindByKey looks the key up by its prefix and digest; the plaintext is
+// never sent to the database. The status of the account is loaded along
+// with the key so authentication takes a single query.
+func (r *APIKeyRepository) FindByKey(ctx context.Context, key string) (*domain.APIKey, error) {
+	var accountStatus domain.AccountStatus
+
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`, (SELECT status FROM accounts WHERE accounts.id = api_keys.account_id)
+		FROM api_keys
+		WHERE key_prefix = $1 AND key_hash = $2
+	`, domain.APIKeyPrefix(key), domain.HashAPIKey(key)), &accountStatus)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	apiKey.AccountStatus = accountStatus
+
+	return apiKey, nil
+}
+
+func (r *APIKeyRepository) FindByAccountID(ctx context.Context, accountID string) ([]*domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE account_id = $1
+		ORDER BY created_at, id
+	`, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, key *domain.APIKey) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = $1, expires_at = $2
+		WHERE id = $3 AND revoked_at IS NULL
+	`, key.RevokedAt, key.ExpiresAt, key.ID)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrAPIKeyRevoked
+	}
+
+	return nil
+}
+
+// RevokeAll revokes every key of the account at revokedAt and ends the
+// grace period of keys revoked earlier, so none of them works afterwards.
+func (r *APIKeyRepository) RevokeAll(ctx context.Context, accountID string, revokedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $1), expires_at = $1
+		WHERE account_id = $2 AND (expires_at IS NULL OR expires_at > $1)
+	`, revokedAt, accountID)
+
+	return err
+}
+
+// TouchLastUsed skips keys used within domain.LastUsedResolution, which
+// concurrent requests may have recorded since the key was loaded.
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, usedAt, id, usedAt.Add(-domain.LastUsedResolution))
+
+	return err
+}
+
+// scanAPIKey scans the apiKeyColumns of a row, followed by any extra
+// columns into extra.
+func scanAPIKey(row rowScanner, extra ...any) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var lastUsedAt, revokedAt, expiresAt sql.NullTime
+
+	dest := []any{
+		&key.ID,
+		&key.AccountID,
+		&key.Prefix,
+		&key.Hash,
+		&key.Label,
+		&key.CreatedAt,
+		&lastUsedAt,
+		&revokedAt,
+		&expiresAt,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type CardTokenRepository struct {
+	db DBTX
+}
+
+func NewCardTokenRepository(db DBTX) *CardTokenRepository {
+	return &CardTokenRepository{db: traceDB(db)}
+}
+
+func (r *CardTokenRepository) Create(ctx context.Context, token *domain.CardToken) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO card_tokens (id, account_id, encrypted_number, brand, last_digits, expiry_month, expiry_year, cardholder_name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		token.ID, token.AccountID, token.EncryptedNumber, token.Brand, token.LastDigits, token.ExpiryMonth, token.ExpiryYear, token.CardholderName, token.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *CardTokenRepository) FindByID(ctx context.Context, accountID, id string) (*domain.CardToken, error) {
+	var token domain.CardToken
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, account_id, encrypted_number, brand, last_digits, expiry_month, expiry_year, cardholder_name, created_at
+		FROM card_tokens
+		WHERE id = $1 AND account_id = $2
+	`, id, accountID).Scan(
+		&token.ID,
+		&token.AccountID,
+		&token.EncryptedNumber,
+		&token.Brand,
+		&token.LastDigits,
+		&token.ExpiryMonth,
+		&token.ExpiryYear,
+		&token.CardholderName,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrCardTokenNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// DeleteByAccountID erases the cards stored for the account. Invoices only
+// keep the brand and last digits of their card, so they are unaffected.
+func (r *CardTokenRepository) DeleteByAccountID(ctx context.Context, accountID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM card_tokens WHERE account_id = $1`, accountID)
+
+	return err
+}
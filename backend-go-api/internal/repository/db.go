@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/devfullcycle/imersao22/go-gateway/internal/repository")
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so the same repository
+// code can run standalone or inside a UnitOfWork transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// tracedDB records a client span for every statement run through db.
+type tracedDB struct {
+	db DBTX
+}
+
+// traceDB wraps db so its statements are traced. Repositories wrap the
+// connection they are given, so they are traced standalone and inside a
+// UnitOfWork alike.
+func traceDB(db DBTX) DBTX {
+	if _, ok := db.(*tracedDB); ok {
+		return db
+	}
+
+	return &tracedDB{db: db}
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := t.db.ExecContext(ctx, query, args...)
+	recordQueryError(span, err)
+	return result, err
+}
+
+func (t *tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	stmt, err := t.db.PrepareContext(ctx, query)
+	recordQueryError(span, err)
+	return stmt, err
+}
+
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	recordQueryError(span, err)
+	return rows, err
+}
+
+// QueryRowContext ends its span before the row is scanned, so errors such
+// as sql.ErrNoRows are not recorded on it.
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	return t.db.QueryRowContext(ctx, query, args...)
+}
+
+// startQuerySpan names the span after the SQL operation (SELECT, INSERT,
+// ...) to keep span names low-cardinality; the statement goes in an
+// attribute.
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	query = strings.TrimSpace(query)
+
+	operation, _, _ := strings.Cut(query, " ")
+	operation = strings.ToUpper(strings.TrimSpace(operation))
+
+	return startSpan(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", query),
+		),
+	)
+}
+
+// startSpan only starts spans that belong to a trace, so the polling of the
+// background workers does not start a new trace every few seconds.
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	return tracer.Start(ctx, name, opts...)
+}
+
+func recordQueryError(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// withTx runs fn in a transaction. When db is already a transaction fn
+// joins it and the caller stays responsible for commit/rollback.
+func withTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	if traced, ok := db.(*tracedDB); ok {
+		db = traced.db
+	}
+
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(traceDB(db))
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err := fn(traceDB(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// placeholder returns the Postgres positional parameter for index n ($n),
+// used when building queries with optional filters.
+func placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
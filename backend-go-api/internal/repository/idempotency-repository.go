@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type IdempotencyRepository struct {
+	db DBTX
+}
+
+func NewIdempotencyRepository(db DBTX) *IdempotencyRepository {
+	return &IdempotencyRepository{db: traceDB(db)}
+}
+
+// Reserve inserts the key, taking over an existing row only when it has
+// expired, or when it has no response and its reservation ended. A
+// concurrent request holding the same key blocks on the insert until the
+// reservation is committed, and then finds it without a response.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key *domain.IdempotencyKey) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (account_id, idempotency_key, request_hash, created_at, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (account_id, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response_body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at,
+			locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+			OR (idempotency_keys.status_code IS NULL
+				AND (idempotency_keys.locked_until IS NULL OR idempotency_keys.locked_until <= EXCLUDED.created_at))
+	`, key.AccountID, key.Key, key.RequestHash, key.CreatedAt, key.ExpiresAt, key.LockedUntil)
+
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *IdempotencyRepository) FindByKey(ctx context.Context, accountID, key string) (*domain.IdempotencyKey, error) {
+	var idempotencyKey domain.IdempotencyKey
+	var statusCode sql.NullInt64
+	var createdAt, expiresAt time.Time
+	var lockedUntil sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT account_id, idempotency_key, request_hash, status_code, response_body, created_at, expires_at, locked_until
+		FROM idempotency_keys
+		WHERE account_id = $1 AND idempotency_key = $2
+	`, accountID, key).Scan(
+		&idempotencyKey.AccountID,
+		&idempotencyKey.Key,
+		&idempotencyKey.RequestHash,
+		&statusCode,
+		&idempotencyKey.ResponseBody,
+		&createdAt,
+		&expiresAt,
+		&lockedUntil,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+
+	idempotencyKey.StatusCode = int(statusCode.Int64)
+	idempotencyKey.CreatedAt = createdAt
+	idempotencyKey.ExpiresAt = expiresAt
+	idempotencyKey.LockedUntil = lockedUntil.Time
+
+	return &idempotencyKey, nil
+}
+
+func (r *IdempotencyRepository) SaveResponse(ctx context.Context, key *domain.IdempotencyKey) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $1, response_body = $2
+		WHERE account_id = $3 AND idempotency_key = $4
+	`, key.StatusCode, key.ResponseBody, key.AccountID, key.Key)
+
+	return err
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, key *domain.IdempotencyKey) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE account_id = $1 AND idempotency_key = $2 AND status_code IS NULL
+	`, key.AccountID, key.Key)
+
+	return err
+}
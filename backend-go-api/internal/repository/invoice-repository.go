@@ -1,25 +1,31 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
 	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/lib/pq"
 )
 
+const invoiceColumns = `id, account_id, amount, currency, captured_amount, refunded_amount, status, auto_capture, authorization_expires_at, description, payment_type, card_last_digits, card_brand, created_at, updated_at`
+
 type InvoiceRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewInvoiceRepository(db *sql.DB) *InvoiceRepository {
-	return &InvoiceRepository{db: db}
+func NewInvoiceRepository(db DBTX) *InvoiceRepository {
+	return &InvoiceRepository{db: traceDB(db)}
 }
 
-func (r *InvoiceRepository) CreateInvoice(invoice *domain.Invoice) error {
-	_, err := r.db.Exec(
-		`INSERT INTO invoices (id, account_id, amount, status, description, payment_type, card_last_digits, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		invoice.ID, invoice.AccountID, invoice.Amount, invoice.Status, invoice.Description, invoice.PaymentType, invoice.CardLastDigits, invoice.CreatedAt, invoice.UpdatedAt,
+func (r *InvoiceRepository) CreateInvoice(ctx context.Context, invoice *domain.Invoice) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO invoices (id, account_id, amount, currency, captured_amount, refunded_amount, status, auto_capture, authorization_expires_at, description, payment_type, card_last_digits, card_brand, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		invoice.ID, invoice.AccountID, invoice.Amount, invoice.Amount.Currency, invoice.CapturedAmount, invoice.RefundedAmount, invoice.Status, invoice.AutoCapture, invoice.AuthorizationExpiresAt, invoice.Description, invoice.PaymentType, invoice.CardLastDigits, invoice.CardBrand, invoice.CreatedAt, invoice.UpdatedAt,
 	)
 
 	if err != nil {
@@ -29,24 +35,32 @@ func (r *InvoiceRepository) CreateInvoice(invoice *domain.Invoice) error {
 	return nil
 }
 
-func (r *InvoiceRepository) FindByID(id string) (*domain.Invoice, error) {
-	var invoice domain.Invoice
+func (r *InvoiceRepository) FindByID(ctx context.Context, id string) (*domain.Invoice, error) {
+	invoice, err := scanInvoice(r.db.QueryRowContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE id = $1
+	`, id))
 
-	err := r.db.QueryRow(`
-		SELECT id, account_id, amount, status, description, payment_type, card_last_digits, created_at, updated_at
-		FROM invoices 
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// FindByIDForUpdate loads the invoice and locks its row until the current
+// transaction ends. It only makes sense inside a UnitOfWork.
+func (r *InvoiceRepository) FindByIDForUpdate(ctx context.Context, id string) (*domain.Invoice, error) {
+	invoice, err := scanInvoice(r.db.QueryRowContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
 		WHERE id = $1
-	`, id).Scan(
-		&invoice.ID,
-		&invoice.AccountID,
-		&invoice.Amount,
-		&invoice.Status,
-		&invoice.Description,
-		&invoice.PaymentType,
-		&invoice.CardLastDigits,
-		&invoice.CreatedAt,
-		&invoice.UpdatedAt,
-	)
+		FOR UPDATE
+	`, id))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -55,16 +69,38 @@ func (r *InvoiceRepository) FindByID(id string) (*domain.Invoice, error) {
 		return nil, err
 	}
 
-	return &invoice, nil
+	return invoice, nil
 }
 
-func (r *InvoiceRepository) FindByAccountID(accountID string) ([]*domain.Invoice, error) {
-	rows, err := r.db.Query(`
-		SELECT id, account_id, amount, status, description, payment_type, card_last_digits, created_at, updated_at
-		FROM invoices 
-		WHERE account_id = $1
-	`, accountID)
+// FindByAccountID lists the invoices of an account matching filter in the
+// order of filter.Sort, starting after cursor when it is not nil.
+func (r *InvoiceRepository) FindByAccountID(ctx context.Context, accountID string, filter domain.InvoiceFilter, cursor *domain.Cursor, limit int) ([]*domain.Invoice, error) {
+	where, args := invoiceFilterClause(accountID, filter)
+	column, direction := invoiceSortColumn(filter.Sort)
+
+	comparison := "<"
+	if direction == "ASC" {
+		comparison = ">"
+	}
+
+	if cursor != nil {
+		if column == "created_at" {
+			where += ` AND (created_at, id) ` + comparison + ` (` + placeholder(len(args)+1) + `, ` + placeholder(len(args)+2) + `)`
+			args = append(args, cursor.CreatedAt, cursor.ID)
+		} else {
+			// The cursor only carries created_at, so the amount of the last
+			// row is looked up by id
+			where += ` AND (amount, id) ` + comparison + ` (SELECT amount, id FROM invoices WHERE id = ` + placeholder(len(args)+1) + `)`
+			args = append(args, cursor.ID)
+		}
+	}
+
+	query := `SELECT ` + invoiceColumns + ` FROM invoices WHERE ` + where +
+		` ORDER BY ` + column + ` ` + direction + `, id ` + direction +
+		` LIMIT ` + placeholder(len(args)+1)
+	args = append(args, limit)
 
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -73,42 +109,81 @@ func (r *InvoiceRepository) FindByAccountID(accountID string) ([]*domain.Invoice
 
 	var invoices []*domain.Invoice
 	for rows.Next() {
-		var invoice domain.Invoice
-
-		if err := rows.Scan(
-			&invoice.ID,
-			&invoice.AccountID,
-			&invoice.Amount,
-			&invoice.Status,
-			&invoice.Description,
-			&invoice.PaymentType,
-			&invoice.CardLastDigits,
-			&invoice.CreatedAt,
-			&invoice.UpdatedAt,
-		); err != nil {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
 			return nil, err
 		}
 
-		invoices = append(invoices, &invoice)
+		invoices = append(invoices, invoice)
 	}
 
-	return invoices, nil
+	return invoices, rows.Err()
 }
 
-func (r *InvoiceRepository) UpdateStatus(invoice *domain.Invoice) error {
-	tx, err := r.db.Begin()
+// exportFetchSize is how many rows StreamByAccountID fetches from the server
+// cursor at a time.
+const exportFetchSize = 1000
+
+// StreamByAccountID calls fn for every invoice of the account matching
+// filter, in the order of filter.Sort. Rows are read in batches from a
+// server-side cursor, so memory does not grow with the number of invoices.
+// The cursor lives in a transaction that stays open until fn has seen every
+// row or returned an error.
+func (r *InvoiceRepository) StreamByAccountID(ctx context.Context, accountID string, filter domain.InvoiceFilter, fn func(*domain.Invoice) error) error {
+	where, args := invoiceFilterClause(accountID, filter)
+	column, direction := invoiceSortColumn(filter.Sort)
+
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		if _, err := tx.ExecContext(ctx, `DECLARE invoice_export NO SCROLL CURSOR FOR
+			SELECT `+invoiceColumns+` FROM invoices WHERE `+where+
+			` ORDER BY `+column+` `+direction+`, id `+direction, args...); err != nil {
+			return err
+		}
+
+		for {
+			fetched, err := r.fetchExportBatch(ctx, tx, fn)
+			if err != nil {
+				return err
+			}
+			if fetched < exportFetchSize {
+				return nil
+			}
+		}
+	})
+}
+
+func (r *InvoiceRepository) fetchExportBatch(ctx context.Context, tx DBTX, fn func(*domain.Invoice) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, `FETCH `+strconv.Itoa(exportFetchSize)+` FROM invoice_export`)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	defer tx.Rollback()
+	defer rows.Close()
 
-	// Block concurrent updates
-	res, err := tx.Exec(`
-	UPDATE invoices 
-	SET status = $1, updated_at = $2 
-	WHERE id = $3
-    `, invoice.Status, time.Now(), invoice.ID)
+	fetched := 0
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return fetched, err
+		}
+
+		fetched++
+		if err := fn(invoice); err != nil {
+			return fetched, err
+		}
+	}
+
+	return fetched, rows.Err()
+}
+
+// Update persists the mutable state of the invoice: status and the amounts
+// that change over its lifecycle.
+func (r *InvoiceRepository) Update(ctx context.Context, invoice *domain.Invoice) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE invoices
+		SET status = $1, captured_amount = $2, refunded_amount = $3, authorization_expires_at = $4, updated_at = $5
+		WHERE id = $6
+	`, invoice.Status, invoice.CapturedAmount, invoice.RefundedAmount, invoice.AuthorizationExpiresAt, invoice.UpdatedAt, invoice.ID)
 
 	if err != nil {
 		return err
@@ -122,5 +197,133 @@ func (r *InvoiceRepository) UpdateStatus(invoice *domain.Invoice) error {
 		return domain.ErrInvoiceNotFound
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// FindExpiredAuthorizations returns authorized invoices whose capture window
+// ended before the given time, locking them for the current transaction.
+// Rows already locked by another instance are skipped.
+func (r *InvoiceRepository) FindExpiredAuthorizations(ctx context.Context, before time.Time, limit int) ([]*domain.Invoice, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+invoiceColumns+`
+		FROM invoices
+		WHERE status = $1 AND authorization_expires_at <= $2
+		ORDER BY authorization_expires_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, domain.StatusAuthorized, before, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var invoices []*domain.Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices, rows.Err()
+}
+
+// invoiceFilterClause builds the WHERE conditions of an account listing and
+// their arguments, numbered from $1.
+func invoiceFilterClause(accountID string, filter domain.InvoiceFilter) (string, []any) {
+	where := `account_id = $1`
+	args := []any{accountID}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		args = append(args, pq.Array(statuses))
+		where += ` AND status = ANY(` + placeholder(len(args)) + `)`
+	}
+	if filter.PaymentType != "" {
+		args = append(args, filter.PaymentType)
+		where += ` AND payment_type = ` + placeholder(len(args))
+	}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		where += ` AND currency = ` + placeholder(len(args))
+	}
+	if filter.MinAmount != nil {
+		args = append(args, *filter.MinAmount)
+		where += ` AND amount >= ` + placeholder(len(args))
+	}
+	if filter.MaxAmount != nil {
+		args = append(args, *filter.MaxAmount)
+		where += ` AND amount <= ` + placeholder(len(args))
+	}
+	if filter.CreatedFrom != nil {
+		args = append(args, *filter.CreatedFrom)
+		where += ` AND created_at >= ` + placeholder(len(args))
+	}
+	if filter.CreatedTo != nil {
+		args = append(args, *filter.CreatedTo)
+		where += ` AND created_at < ` + placeholder(len(args))
+	}
+
+	return where, args
+}
+
+// invoiceSortColumn maps a sort to its column and direction. Both come from
+// a fixed set, so they are safe to put in the query.
+func invoiceSortColumn(sort domain.InvoiceSort) (string, string) {
+	switch sort {
+	case domain.InvoiceSortCreatedAtAsc:
+		return "created_at", "ASC"
+	case domain.InvoiceSortAmountDesc:
+		return "amount", "DESC"
+	case domain.InvoiceSortAmountAsc:
+		return "amount", "ASC"
+	default:
+		return "created_at", "DESC"
+	}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvoice(row rowScanner) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	var currency string
+	var authorizationExpiresAt sql.NullTime
+
+	if err := row.Scan(
+		&invoice.ID,
+		&invoice.AccountID,
+		&invoice.Amount,
+		&currency,
+		&invoice.CapturedAmount,
+		&invoice.RefundedAmount,
+		&invoice.Status,
+		&invoice.AutoCapture,
+		&authorizationExpiresAt,
+		&invoice.Description,
+		&invoice.PaymentType,
+		&invoice.CardLastDigits,
+		&invoice.CardBrand,
+		&invoice.CreatedAt,
+		&invoice.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	invoice.Amount.Currency = currency
+	invoice.CapturedAmount.Currency = currency
+	invoice.RefundedAmount.Currency = currency
+	if authorizationExpiresAt.Valid {
+		invoice.AuthorizationExpiresAt = &authorizationExpiresAt.Time
+	}
+
+	return &invoice, nil
 }
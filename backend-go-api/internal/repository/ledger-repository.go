@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type LedgerRepository struct {
+	db DBTX
+}
+
+func NewLedgerRepository(db DBTX) *LedgerRepository {
+	return &LedgerRepository{db: traceDB(db)}
+}
+
+func (r *LedgerRepository) Append(ctx context.Context, transaction *domain.LedgerTransaction) error {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
+
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		stmt, err := tx.PrepareContext(ctx, `
+			INSERT INTO ledger_entries (id, transaction_id, account_id, ledger_account, direction, amount, currency, entry_type, reference_id, description, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::uuid, $10, $11)
+		`)
+		if err != nil {
+			return err
+		}
+
+		defer stmt.Close()
+
+		for _, entry := range transaction.Entries {
+			if _, err := stmt.ExecContext(
+				ctx,
+				entry.ID,
+				entry.TransactionID,
+				entry.AccountID,
+				entry.LedgerAccount,
+				entry.Direction,
+				entry.Amount,
+				entry.Amount.Currency,
+				entry.Type,
+				entry.ReferenceID,
+				entry.Description,
+				entry.CreatedAt,
+			); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *LedgerRepository) FindByAccountID(ctx context.Context, accountID string, cursor *domain.Cursor, limit int) ([]*domain.LedgerEntry, error) {
+	query := `
+		SELECT id, transaction_id, account_id, ledger_account, direction, amount, currency, entry_type, reference_id, description, created_at
+		FROM ledger_entries
+		WHERE account_id = $1`
+	args := []any{accountID}
+
+	if cursor != nil {
+		query += ` AND (created_at, id) < ($2, $3)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + placeholder(len(args)+1)
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var entries []*domain.LedgerEntry
+	for rows.Next() {
+		var entry domain.LedgerEntry
+		var currency string
+		var referenceID sql.NullString
+
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.TransactionID,
+			&entry.AccountID,
+			&entry.LedgerAccount,
+			&entry.Direction,
+			&entry.Amount,
+			&currency,
+			&entry.Type,
+			&referenceID,
+			&entry.Description,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		entry.Amount.Currency = currency
+		entry.ReferenceID = referenceID.String
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *LedgerRepository) Balances(ctx context.Context, accountID string) ([]domain.Money, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT currency, SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END)
+		FROM ledger_entries
+		WHERE account_id = $1 AND ledger_account = $2
+		GROUP BY currency
+		ORDER BY currency
+	`, accountID, domain.LedgerAccountMerchant)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var balances []domain.Money
+	for rows.Next() {
+		var currency string
+		var balance domain.Money
+
+		if err := rows.Scan(&currency, &balance); err != nil {
+			return nil, err
+		}
+
+		balance.Currency = currency
+		balances = append(balances, balance)
+	}
+
+	return balances, rows.Err()
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type OutboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db DBTX) *OutboxRepository {
+	return &OutboxRepository{db: traceDB(db)}
+}
+
+func (r *OutboxRepository) Save(ctx context.Context, message *domain.OutboxMessage) error {
+	traceContext, err := json.Marshal(message.TraceContext)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		`INSERT INTO outbox (id, aggregate_id, event_type, payload, trace_context, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		message.ID, message.AggregateID, message.EventType, message.Payload, traceContext, message.Status, message.Attempts, message.NextAttemptAt, message.CreatedAt,
+	)
+
+	return err
+}
+
+// ClaimPending leases the oldest pending messages due before the given time
+// in a single statement. The lease is the next attempt time: the messages
+// stay pending, other relays skip them until leaseUntil, and they become due
+// again if the caller dies before recording an outcome. SKIP LOCKED keeps
+// concurrent claims from waiting on each other.
+func (r *OutboxRepository) ClaimPending(ctx context.Context, before time.Time, limit int, leaseUntil time.Time) ([]*domain.OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox
+		SET next_attempt_at = $3
+		WHERE id IN (
+			SELECT id
+			FROM outbox
+			WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY created_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, aggregate_id, event_type, payload, trace_context, status, attempts, last_error, next_attempt_at, created_at, sent_at
+	`, domain.OutboxStatusPending, before, leaseUntil, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []*domain.OutboxMessage
+	for rows.Next() {
+		var message domain.OutboxMessage
+		var lastError sql.NullString
+		var sentAt sql.NullTime
+		var traceContext []byte
+
+		if err := rows.Scan(
+			&message.ID,
+			&message.AggregateID,
+			&message.EventType,
+			&message.Payload,
+			&traceContext,
+			&message.Status,
+			&message.Attempts,
+			&lastError,
+			&message.NextAttemptAt,
+			&message.CreatedAt,
+			&sentAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(traceContext, &message.TraceContext); err != nil {
+			return nil, err
+		}
+
+		message.LastError = lastError.String
+		if sentAt.Valid {
+			message.SentAt = &sentAt.Time
+		}
+
+		messages = append(messages, &message)
+	}
+
+	return messages, rows.Err()
+}
+
+func (r *OutboxRepository) Update(ctx context.Context, message *domain.OutboxMessage) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET status = $1, attempts = $2, last_error = NULLIF($3, ''), next_attempt_at = $4, sent_at = $5
+		WHERE id = $6
+	`, message.Status, message.Attempts, message.LastError, message.NextAttemptAt, message.SentAt, message.ID)
+
+	return err
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+const (
+	// rateLimitPruneInterval is how often each instance deletes full
+	// buckets. A full bucket holds no state worth keeping: Take treats a
+	// missing row like a TAT in the past.
+	rateLimitPruneInterval = time.Minute
+	// rateLimitPruneBatch caps the rows deleted at once, keeping the request
+	// that triggers the prune fast.
+	rateLimitPruneBatch = 1000
+)
+
+// RateLimitRepository stores rate limit buckets in Postgres so that every
+// instance of the gateway shares them.
+type RateLimitRepository struct {
+	db DBTX
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewRateLimitRepository(db DBTX) *RateLimitRepository {
+	return &RateLimitRepository{db: traceDB(db)}
+}
+
+// Take moves the TAT of the bucket in a single statement. A denied request
+// leaves the row untouched and returns no row, so the TAT is read again to
+// tell the client when to retry.
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	if r.pruneDue(now) {
+		if err := r.prune(ctx, now); err != nil {
+			slog.Error("erro ao remover buckets de rate limit expirados", "error", err)
+		}
+	}
+
+	interval := limit.Interval().Microseconds()
+
+	var next time.Time
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limits AS l (key, tat)
+		VALUES ($1, $2::timestamptz + $3::bigint * INTERVAL '1 microsecond')
+		ON CONFLICT (key) DO UPDATE
+		SET tat = GREATEST(l.tat, $2::timestamptz) + $3::bigint * INTERVAL '1 microsecond'
+		WHERE GREATEST(l.tat, $2::timestamptz) + $3::bigint * INTERVAL '1 microsecond' <= $4::timestamptz
+		RETURNING tat
+	`, key, now, interval, now.Add(limit.Period)).Scan(&next)
+
+	if err == nil {
+		return limit.Allowed(next, now), nil
+	}
+	if err != sql.ErrNoRows {
+		return domain.RateLimitResult{}, err
+	}
+
+	var tat time.Time
+	if err := r.db.QueryRowContext(ctx, `
+		SELECT tat FROM rate_limits WHERE key = $1
+	`, key).Scan(&tat); err != nil {
+		return domain.RateLimitResult{}, err
+	}
+
+	return limit.Denied(tat, now), nil
+}
+
+func (r *RateLimitRepository) pruneDue(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastPrune) < rateLimitPruneInterval {
+		return false
+	}
+	r.lastPrune = now
+
+	return true
+}
+
+// prune deletes buckets whose TAT is not after now. Rows a concurrent Take
+// is moving are skipped, and the TAT is checked again once locked.
+func (r *RateLimitRepository) prune(ctx context.Context, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM rate_limits
+		WHERE tat <= $1 AND key IN (
+			SELECT key FROM rate_limits
+			WHERE tat <= $1
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`, now, rateLimitPruneBatch)
+
+	return err
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type RefundRepository struct {
+	db DBTX
+}
+
+func NewRefundRepository(db DBTX) *RefundRepository {
+	return &RefundRepository{db: traceDB(db)}
+}
+
+func (r *RefundRepository) Create(ctx context.Context, refund *domain.Refund) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO refunds (id, invoice_id, account_id, amount, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		refund.ID, refund.InvoiceID, refund.AccountID, refund.Amount, refund.Amount.Currency, refund.CreatedAt,
+	)
+
+	return err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/devfullcycle/imersao22/go-gateway/migrations"
+)
+
+// SchemaVersion is the migration version this build expects: the highest
+// numeric prefix among the files in migrations/.
+var SchemaVersion = latestMigration(migrations.Files)
+
+// latestMigration returns the highest version of the golang-migrate files
+// in files, named <version>_<title>.<up|down>.sql.
+func latestMigration(files fs.FS) uint {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		panic("repository: cannot read migrations: " + err.Error())
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			continue
+		}
+
+		version, err := strconv.ParseUint(prefix, 10, 0)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, uint(version))
+	}
+
+	return latest
+}
+
+// SchemaRepository reads the state of the database itself rather than of
+// any table.
+type SchemaRepository struct {
+	db *sql.DB
+}
+
+func NewSchemaRepository(db *sql.DB) *SchemaRepository {
+	return &SchemaRepository{db: db}
+}
+
+func (r *SchemaRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// MigrationVersion returns the version recorded by golang-migrate and
+// whether the last migration failed halfway.
+func (r *SchemaRepository) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	var version uint
+	var dirty bool
+
+	err := r.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/devfullcycle/imersao22/go-gateway/migrations"
+)
+
+func TestLatestMigration(t *testing.T) {
+	files := fstest.MapFS{
+		"000001_create_accounts_table.up.sql":   {},
+		"000001_create_accounts_table.down.sql": {},
+		"000009_add_column.up.sql":              {},
+		"000010_add_index.up.sql":               {},
+		"000010_add_index.down.sql":             {},
+		"README.md":                             {},
+		"draft_migration.sql":                   {},
+	}
+
+	if got := latestMigration(files); got != 10 {
+		t.Errorf("latestMigration() = %d, want 10", got)
+	}
+	if got := latestMigration(fstest.MapFS{}); got != 0 {
+		t.Errorf("latestMigration() of no files = %d, want 0", got)
+	}
+}
+
+// Every version up to SchemaVersion must come with both directions, or
+// golang-migrate cannot reach it or roll it back.
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	if SchemaVersion == 0 {
+		t.Fatal("SchemaVersion = 0, want the embedded migrations to be found")
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		matches, err := fs.Glob(migrations.Files, "*."+direction+".sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint(len(matches)) != SchemaVersion {
+			t.Errorf("%d %s migrations, want one per version up to %d", len(matches), direction, SchemaVersion)
+		}
+	}
+}
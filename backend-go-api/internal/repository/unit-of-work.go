@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"go.opentelemetry.io/otel/codes"
+)
+
+type UnitOfWork struct {
+	db *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos *domain.Repositories) error) error {
+	ctx, span := startSpan(ctx, "UnitOfWork.Do")
+	defer span.End()
+
+	err := withTx(ctx, u.db, func(tx DBTX) error {
+		return fn(ctx, &domain.Repositories{
+			Accounts:        NewAccountRepository(tx),
+			APIKeys:         NewAPIKeyRepository(tx),
+			CardTokens:      NewCardTokenRepository(tx),
+			Invoices:        NewInvoiceRepository(tx),
+			Outbox:          NewOutboxRepository(tx),
+			IdempotencyKeys: NewIdempotencyRepository(tx),
+			Ledger:          NewLedgerRepository(tx),
+			Refunds:         NewRefundRepository(tx),
+			Webhooks:        NewWebhookRepository(tx),
+		})
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/lib/pq"
+)
+
+const (
+	webhookEndpointColumns = `id, account_id, url, secret, event_types, active, created_at, updated_at`
+	webhookDeliveryColumns = `id, endpoint_id, account_id, event_type, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at`
+)
+
+type WebhookRepository struct {
+	db DBTX
+}
+
+func NewWebhookRepository(db DBTX) *WebhookRepository {
+	return &WebhookRepository{db: traceDB(db)}
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_endpoints (id, account_id, url, secret, event_types, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		endpoint.ID, endpoint.AccountID, endpoint.URL, endpoint.Secret, pq.Array(eventTypeStrings(endpoint.EventTypes)), endpoint.Active, endpoint.CreatedAt, endpoint.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *WebhookRepository) UpdateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_endpoints
+		SET url = $1, event_types = $2, active = $3, updated_at = $4
+		WHERE id = $5
+	`, endpoint.URL, pq.Array(eventTypeStrings(endpoint.EventTypes)), endpoint.Active, endpoint.UpdatedAt, endpoint.ID)
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return domain.ErrWebhookEndpointNotFound
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) FindEndpoint(ctx context.Context, accountID, id string) (*domain.WebhookEndpoint, error) {
+	endpoint, err := scanWebhookEndpoint(r.db.QueryRowContext(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE id = $1 AND account_id = $2
+	`, id, accountID))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrWebhookEndpointNotFound
+		}
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+func (r *WebhookRepository) FindEndpointsByAccountID(ctx context.Context, accountID string) ([]*domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE account_id = $1
+		ORDER BY created_at
+	`, accountID)
+}
+
+func (r *WebhookRepository) FindSubscribedEndpoints(ctx context.Context, accountID string, eventType domain.WebhookEventType) ([]*domain.WebhookEndpoint, error) {
+	return r.queryEndpoints(ctx, `
+		SELECT `+webhookEndpointColumns+`
+		FROM webhook_endpoints
+		WHERE account_id = $1 AND active AND $2 = ANY(event_types)
+		ORDER BY created_at
+	`, accountID, string(eventType))
+}
+
+func (r *WebhookRepository) queryEndpoints(ctx context.Context, query string, args ...any) ([]*domain.WebhookEndpoint, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var endpoints []*domain.WebhookEndpoint
+	for rows.Next() {
+		endpoint, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (id, endpoint_id, account_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		delivery.ID, delivery.EndpointID, delivery.AccountID, delivery.EventType, delivery.Payload, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, last_error = NULLIF($3, ''), next_attempt_at = $4, delivered_at = $5, locked_until = NULL
+		WHERE id = $6
+	`, delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID)
+
+	return err
+}
+
+// RedeliverDelivery only updates deliveries that are not leased, so a
+// dispatcher sending the delivery does not overwrite the redelivery when it
+// records its outcome.
+func (r *WebhookRepository) RedeliverDelivery(ctx context.Context, delivery *domain.WebhookDelivery, now time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, last_error = NULLIF($3, ''), next_attempt_at = $4, delivered_at = $5
+		WHERE id = $6 AND (locked_until IS NULL OR locked_until <= $7)
+	`, delivery.Status, delivery.Attempts, delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID, now)
+
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *WebhookRepository) FindDelivery(ctx context.Context, endpointID, id string) (*domain.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE id = $1 AND endpoint_id = $2
+	`, id, endpointID))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (r *WebhookRepository) FindDeliveries(ctx context.Context, endpointID string, cursor *domain.Cursor, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1`
+	args := []any{endpointID}
+
+	if cursor != nil {
+		query += ` AND (created_at, id) < ($2, $3)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + placeholder(len(args)+1)
+	args = append(args, limit)
+
+	return r.queryDeliveries(ctx, query, args...)
+}
+
+// ClaimDueDeliveries leases the oldest pending deliveries due before the
+// given time in a single statement. The lease moves the next attempt time:
+// the deliveries stay pending, other dispatchers skip them until leaseUntil,
+// and they become due again if the caller dies before recording an outcome.
+// locked_until marks the lease so redeliveries can tell it apart from a
+// retry delay. SKIP LOCKED keeps concurrent claims from waiting on each
+// other.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, before time.Time, limit int, leaseUntil time.Time) ([]*domain.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $3, locked_until = $3
+		WHERE id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= $2
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns+`
+	`, domain.WebhookDeliveryPending, before, leaseUntil, limit)
+}
+
+func (r *WebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]*domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *WebhookRepository) AddAttempt(ctx context.Context, attempt *domain.WebhookDeliveryAttempt) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_delivery_attempts (id, delivery_id, response_status, response_body, error, duration_ms, attempted_at)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, ''), $6, $7)`,
+		attempt.ID, attempt.DeliveryID, attempt.ResponseStatus, attempt.ResponseBody, attempt.Error, attempt.Duration.Milliseconds(), attempt.AttemptedAt,
+	)
+
+	return err
+}
+
+func (r *WebhookRepository) FindAttempts(ctx context.Context, deliveryID string) ([]*domain.WebhookDeliveryAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, delivery_id, response_status, response_body, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY attempted_at
+	`, deliveryID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var attempts []*domain.WebhookDeliveryAttempt
+	for rows.Next() {
+		var attempt domain.WebhookDeliveryAttempt
+		var responseStatus sql.NullInt64
+		var responseBody, attemptErr sql.NullString
+		var durationMs int64
+
+		if err := rows.Scan(
+			&attempt.ID,
+			&attempt.DeliveryID,
+			&responseStatus,
+			&responseBody,
+			&attemptErr,
+			&durationMs,
+			&attempt.AttemptedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		attempt.ResponseStatus = int(responseStatus.Int64)
+		attempt.ResponseBody = responseBody.String
+		attempt.Error = attemptErr.String
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		attempts = append(attempts, &attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+func scanWebhookEndpoint(row rowScanner) (*domain.WebhookEndpoint, error) {
+	var endpoint domain.WebhookEndpoint
+	var eventTypes []string
+
+	if err := row.Scan(
+		&endpoint.ID,
+		&endpoint.AccountID,
+		&endpoint.URL,
+		&endpoint.Secret,
+		pq.Array(&eventTypes),
+		&endpoint.Active,
+		&endpoint.CreatedAt,
+		&endpoint.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	for _, eventType := range eventTypes {
+		endpoint.EventTypes = append(endpoint.EventTypes, domain.WebhookEventType(eventType))
+	}
+
+	return &endpoint, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.EndpointID,
+		&delivery.AccountID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&lastError,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	); err != nil {
+		return nil, err
+	}
+
+	delivery.LastError = lastError.String
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return &delivery, nil
+}
+
+func eventTypeStrings(eventTypes []domain.WebhookEventType) []string {
+	values := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		values = append(values, string(eventType))
+	}
+
+	return values
+}
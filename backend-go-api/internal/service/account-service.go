@@ -1,35 +1,64 @@
 package service
 
 import (
-	"log" // Added for logging
+	"context"
+	"log/slog"
+	"sort"
+	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
 )
 
 type AccountService struct {
-	repository domain.AccountRepository
+	repository       domain.AccountRepository
+	ledgerRepository domain.LedgerRepository
+	unitOfWork       domain.UnitOfWork
 }
 
-func NewAccountService(repository domain.AccountRepository) *AccountService {
-	return &AccountService{repository: repository}
+func NewAccountService(repository domain.AccountRepository, ledgerRepository domain.LedgerRepository, unitOfWork domain.UnitOfWork) *AccountService {
+	return &AccountService{
+		repository:       repository,
+		ledgerRepository: ledgerRepository,
+		unitOfWork:       unitOfWork,
+	}
 }
 
-func (s *AccountService) CreateAccount(input *dto.CreateAccountInput) (*dto.AccountResponse, error) {
-	account := dto.ToAccount(input)
+func (s *AccountService) CreateAccount(ctx context.Context, input *dto.CreateAccountInput) (_ *dto.AccountResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.CreateAccount")
+	defer func() { endSpan(span, err) }()
+
+	if err := dto.Validate(input); err != nil {
+		return nil, err
+	}
 
-	existingAccount, err := s.repository.FindByAPIKey(account.APIKey)
+	account := dto.ToAccount(input)
 
-	if err != nil && err != domain.ErrAccountNotFound {
-		log.Printf("ERROR checking for existing API key %s: %v", account.APIKey, err) // Added log
+	err = s.repository.CreateAccount(ctx, account)
+	if err != nil {
 		return nil, err
 	}
 
-	if existingAccount != nil {
-		return nil, domain.ErrDuplicateAPIKey
+	output := dto.FromAccount(account)
+
+	return &output, nil
+}
+
+// GetAccount returns the authenticated account.
+func (s *AccountService) GetAccount(ctx context.Context) (_ *dto.AccountResponse, err error) {
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	err = s.repository.CreateAccount(account)
+	return s.GetAccountByID(ctx, principal.AccountID)
+}
+
+func (s *AccountService) GetAccountByID(ctx context.Context, id string) (_ *dto.AccountResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.GetAccountByID")
+	defer func() { endSpan(span, err) }()
+
+	account, err := s.repository.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -39,44 +68,219 @@ func (s *AccountService) CreateAccount(input *dto.CreateAccountInput) (*dto.Acco
 	return &output, nil
 }
 
-func (s *AccountService) UpdateBalance(apiKey string, amount float64) (*dto.AccountResponse, error) {
-	account, err := s.repository.FindByAPIKey(apiKey)
+// UpdateAccount changes the name and email of the authenticated account.
+func (s *AccountService) UpdateAccount(ctx context.Context, input dto.UpdateAccountInput) (_ *dto.AccountResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.UpdateAccount")
+	defer func() { endSpan(span, err) }()
+
+	if err := dto.Validate(&input); err != nil {
+		return nil, err
+	}
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	return s.changeAccount(ctx, principal.AccountID, func(ctx context.Context, repos *domain.Repositories, account *domain.Account) error {
+		return account.Update(input.Name, input.Email)
+	})
+}
+
+// CloseAccount closes the authenticated account. See CloseAccountByID.
+func (s *AccountService) CloseAccount(ctx context.Context) (_ *dto.AccountResponse, err error) {
+	principal, err := domain.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	account.AddBalance(amount)
+	return s.CloseAccountByID(ctx, principal.AccountID)
+}
+
+// CloseAccountByID closes the account and erases its personal data: the
+// name and email are anonymized, every api key stops working, stored cards
+// are deleted and webhook endpoints are disabled. Invoices, refunds, ledger
+// entries and balances are kept for accounting.
+func (s *AccountService) CloseAccountByID(ctx context.Context, id string) (_ *dto.AccountResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.CloseAccountByID")
+	defer func() { endSpan(span, err) }()
+
+	response, err := s.changeAccount(ctx, id, func(ctx context.Context, repos *domain.Repositories, account *domain.Account) error {
+		now := time.Now()
+		if err := account.Close(now); err != nil {
+			return err
+		}
+
+		if err := repos.APIKeys.RevokeAll(ctx, account.ID, now); err != nil {
+			return err
+		}
+
+		if err := repos.CardTokens.DeleteByAccountID(ctx, account.ID); err != nil {
+			return err
+		}
+
+		endpoints, err := repos.Webhooks.FindEndpointsByAccountID(ctx, account.ID)
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range endpoints {
+			if !endpoint.Active {
+				continue
+			}
 
-	err = s.repository.UpdateBalance(account)
+			endpoint.Disable()
+			if err := repos.Webhooks.UpdateEndpoint(ctx, endpoint); err != nil {
+				return err
+			}
+		}
 
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	output := dto.FromAccount(account)
+	slog.Info("conta encerrada e dados pessoais anonimizados", "account_id", id)
 
-	return &output, nil
+	return response, nil
+}
+
+// SuspendAccount blocks the account from authenticating until it is
+// reactivated.
+func (s *AccountService) SuspendAccount(ctx context.Context, id string) (_ *dto.AccountResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.SuspendAccount")
+	defer func() { endSpan(span, err) }()
+
+	return s.changeAccount(ctx, id, func(ctx context.Context, repos *domain.Repositories, account *domain.Account) error {
+		return account.Suspend()
+	})
 }
 
-func (s *AccountService) GetAccountByKey(apiKey string) (*dto.AccountResponse, error) {
-	account, err := s.repository.FindByAPIKey(apiKey)
+// ReactivateAccount lets a suspended account authenticate again.
+func (s *AccountService) ReactivateAccount(ctx context.Context, id string) (_ *dto.AccountResponse, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.ReactivateAccount")
+	defer func() { endSpan(span, err) }()
+
+	return s.changeAccount(ctx, id, func(ctx context.Context, repos *domain.Repositories, account *domain.Account) error {
+		return account.Reactivate()
+	})
+}
+
+// changeAccount locks the account, applies fn and stores the result in a
+// single transaction.
+func (s *AccountService) changeAccount(ctx context.Context, id string, fn func(ctx context.Context, repos *domain.Repositories, account *domain.Account) error) (*dto.AccountResponse, error) {
+	var response dto.AccountResponse
+
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		account, err := repos.Accounts.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(ctx, repos, account); err != nil {
+			return err
+		}
+
+		if err := repos.Accounts.Update(ctx, account); err != nil {
+			return err
+		}
+
+		response = dto.FromAccount(account)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	output := dto.FromAccount(account)
+	return &response, nil
+}
 
-	return &output, nil
+// GetLedger lists the ledger entries of the account newest first.
+func (s *AccountService) GetLedger(ctx context.Context, cursor string, limit int) (_ *dto.Page[dto.LedgerEntryResponse], err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.GetLedger")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := dto.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = domain.ClampPageLimit(limit)
+
+	// Fetch one extra entry to know whether there is a next page
+	entries, err := s.ledgerRepository.FindByAccountID(ctx, principal.AccountID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dto.Page[dto.LedgerEntryResponse]{Data: make([]dto.LedgerEntryResponse, 0, len(entries))}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[len(entries)-1]
+		page.NextCursor = dto.EncodeCursor(domain.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, entry := range entries {
+		page.Data = append(page.Data, dto.FromLedgerEntry(entry))
+	}
+
+	return page, nil
 }
 
-func (s *AccountService) GetAccountByID(id string) (*dto.AccountResponse, error) {
-	account, err := s.repository.FindByID(id)
+// ReconcileBalances compares the cached balances of the account with the
+// balances derived from its ledger entries.
+func (s *AccountService) ReconcileBalances(ctx context.Context) (_ []dto.BalanceReconciliation, err error) {
+	ctx, span := tracer.Start(ctx, "AccountService.ReconcileBalances")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	output := dto.FromAccount(account)
+	account, err := s.repository.FindByID(ctx, principal.AccountID)
+	if err != nil {
+		return nil, err
+	}
 
-	return &output, nil
+	ledgerBalances, err := s.ledgerRepository.Balances(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	derived := make(map[string]domain.Money)
+	for _, balance := range ledgerBalances {
+		derived[balance.Currency] = balance
+	}
+	for _, balance := range account.Balances() {
+		if _, ok := derived[balance.Currency]; !ok {
+			derived[balance.Currency] = domain.NewMoney(0, balance.Currency)
+		}
+	}
+
+	currencies := make([]string, 0, len(derived))
+	for currency := range derived {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	result := make([]dto.BalanceReconciliation, 0, len(derived))
+	for _, currency := range currencies {
+		ledgerBalance := derived[currency]
+		cachedBalance := account.Balance(currency)
+
+		result = append(result, dto.BalanceReconciliation{
+			Currency:      currency,
+			LedgerBalance: ledgerBalance,
+			CachedBalance: cachedBalance,
+			InSync:        ledgerBalance.Amount == cachedBalance.Amount,
+		})
+	}
+
+	return result, nil
 }
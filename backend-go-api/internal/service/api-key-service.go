@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+)
+
+type APIKeyServiceConfig struct {
+	// MaxGracePeriod caps how long a revoked key may keep working.
+	MaxGracePeriod time.Duration
+}
+
+// NewAPIKeyServiceConfig reads API_KEY_MAX_GRACE_PERIOD.
+func NewAPIKeyServiceConfig() *APIKeyServiceConfig {
+	return &APIKeyServiceConfig{
+		MaxGracePeriod: envDuration("API_KEY_MAX_GRACE_PERIOD", 7*24*time.Hour),
+	}
+}
+
+// APIKeyService manages the api keys of accounts and authenticates requests
+// with them.
+type APIKeyService struct {
+	repository domain.APIKeyRepository
+	unitOfWork domain.UnitOfWork
+	config     *APIKeyServiceConfig
+}
+
+func NewAPIKeyService(repository domain.APIKeyRepository, unitOfWork domain.UnitOfWork, config *APIKeyServiceConfig) *APIKeyService {
+	return &APIKeyService{
+		repository: repository,
+		unitOfWork: unitOfWork,
+		config:     config,
+	}
+}
+
+// Authenticate resolves the principal of an active key and records that the
+// key was used, at most once per domain.LastUsedResolution. Unknown and expired keys, and keys of closed accounts, yield
+// ErrAccountNotFound; keys of suspended accounts yield ErrAccountSuspended.
+func (s *APIKeyService) Authenticate(ctx context.Context, apiKey string) (_ *domain.Principal, err error) {
+	ctx, span := tracer.Start(ctx, "APIKeyService.Authenticate")
+	defer func() { endSpan(span, err) }()
+
+	now := time.Now()
+
+	key, err := s.repository.FindByKey(ctx, apiKey)
+	if err != nil {
+		if err == domain.ErrAPIKeyNotFound {
+			return nil, domain.ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	if !key.IsActive(now) {
+		return nil, domain.ErrAccountNotFound
+	}
+
+	switch key.AccountStatus {
+	case domain.AccountStatusActive:
+	case domain.AccountStatusSuspended:
+		return nil, domain.ErrAccountSuspended
+	default:
+		return nil, domain.ErrAccountNotFound
+	}
+
+	// Recording the use is bookkeeping, so a failure does not reject the
+	// request
+	if key.LastUsedStale(now) {
+		if err := s.repository.TouchLastUsed(ctx, key.ID, now); err != nil {
+			slog.Error("erro ao registrar uso da api key", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	return &domain.Principal{AccountID: key.AccountID, APIKeyID: key.ID}, nil
+}
+
+// CreateKey adds a key to the account. The response carries the full key,
+// which is not returned again.
+func (s *APIKeyService) CreateKey(ctx context.Context, input dto.CreateAPIKeyInput) (_ *dto.APIKeyResponse, err error) {
+	ctx, span := tracer.Start(ctx, "APIKeyService.CreateKey")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := domain.NewAPIKey(principal.AccountID, input.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	response := dto.FromAPIKey(key, time.Now())
+	response.Key = key.Key
+
+	return &response, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context) (_ []dto.APIKeyResponse, err error) {
+	ctx, span := tracer.Start(ctx, "APIKeyService.ListKeys")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.repository.FindByAccountID(ctx, principal.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := make([]dto.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, dto.FromAPIKey(key, now))
+	}
+
+	return response, nil
+}
+
+// RevokeKey revokes a key of the account, letting it work for the grace
+// period of the input. The last key that is not revoked cannot be revoked,
+// so the account always keeps a way in.
+func (s *APIKeyService) RevokeKey(ctx context.Context, id string, input dto.RevokeAPIKeyInput) (_ *dto.APIKeyResponse, err error) {
+	ctx, span := tracer.Start(ctx, "APIKeyService.RevokeKey")
+	defer func() { endSpan(span, err) }()
+
+	gracePeriod, err := dto.ToGracePeriod(input)
+	if err != nil {
+		return nil, err
+	}
+	if gracePeriod > s.config.MaxGracePeriod {
+		return nil, domain.ErrInvalidGracePeriod
+	}
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var response dto.APIKeyResponse
+
+	// The account row is locked so that two requests revoking different keys
+	// cannot both count the other key as remaining and leave none.
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		if _, err := repos.Accounts.FindByIDForUpdate(ctx, principal.AccountID); err != nil {
+			return err
+		}
+
+		keys, err := repos.APIKeys.FindByAccountID(ctx, principal.AccountID)
+		if err != nil {
+			return err
+		}
+
+		var key *domain.APIKey
+		remaining := 0
+		for _, candidate := range keys {
+			switch {
+			case candidate.ID == id:
+				key = candidate
+			case candidate.RevokedAt == nil:
+				remaining++
+			}
+		}
+
+		if key == nil {
+			return domain.ErrAPIKeyNotFound
+		}
+		if key.RevokedAt == nil && remaining == 0 {
+			return domain.ErrLastAPIKey
+		}
+
+		if err := key.Revoke(now, gracePeriod); err != nil {
+			return err
+		}
+
+		if err := repos.APIKeys.Revoke(ctx, key); err != nil {
+			return err
+		}
+
+		response = dto.FromAPIKey(key, now)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// authorizationExpiryBatchSize bounds how many invoices are expired per
+// transaction.
+const authorizationExpiryBatchSize = 100
+
+// AuthorizationExpirer periodically expires authorizations that were not
+// captured within their capture window.
+type AuthorizationExpirer struct {
+	invoiceService *InvoiceService
+	interval       time.Duration
+}
+
+func NewAuthorizationExpirer(invoiceService *InvoiceService, interval time.Duration) *AuthorizationExpirer {
+	return &AuthorizationExpirer{
+		invoiceService: invoiceService,
+		interval:       interval,
+	}
+}
+
+// Run expires authorizations until ctx is cancelled.
+func (e *AuthorizationExpirer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			// The current batch is finished even if shutdown was requested
+			expired, err := e.invoiceService.ExpireAuthorizations(context.WithoutCancel(ctx), time.Now(), authorizationExpiryBatchSize)
+			if err != nil {
+				slog.Error("erro ao expirar autorizações", "error", err)
+				break
+			}
+			if expired > 0 {
+				slog.Info("autorizações expiradas", "count", expired)
+			}
+			if expired < authorizationExpiryBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
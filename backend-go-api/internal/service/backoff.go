@@ -0,0 +1,13 @@
+package service
+
+import "time"
+
+// exponentialBackoff doubles base for every previous attempt, up to maxDelay.
+func exponentialBackoff(base, maxDelay time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
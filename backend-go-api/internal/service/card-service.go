@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+)
+
+type CardServiceConfig struct {
+	// EncryptionKey is the AES-256 key used to encrypt card numbers.
+	EncryptionKey []byte
+}
+
+// NewCardServiceConfig reads CARD_ENCRYPTION_KEY, a base64 encoded 32-byte
+// key.
+func NewCardServiceConfig() (*CardServiceConfig, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("CARD_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, domain.ErrInvalidEncryptionKey
+	}
+
+	return &CardServiceConfig{EncryptionKey: key}, nil
+}
+
+// CardService is the card vault. Card numbers are sealed with AES-GCM,
+// bound to their token and account, and CVVs are dropped after validation.
+type CardService struct {
+	repository domain.CardTokenRepository
+	aead       cipher.AEAD
+}
+
+func NewCardService(repository domain.CardTokenRepository, config *CardServiceConfig) (*CardService, error) {
+	block, err := aes.NewCipher(config.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CardService{
+		repository: repository,
+		aead:       aead,
+	}, nil
+}
+
+// Tokenize stores the card and returns its token.
+func (s *CardService) Tokenize(ctx context.Context, input dto.CreateCardTokenInput) (_ *dto.CardTokenResponse, err error) {
+	ctx, span := tracer.Start(ctx, "CardService.Tokenize")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	card := dto.ToTokenizedCard(&input)
+
+	token, err := domain.NewCardToken(principal.AccountID, card)
+	if err != nil {
+		return nil, err
+	}
+
+	token.EncryptedNumber, err = s.encrypt(token, domain.NormalizeCardNumber(card.Number))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return dto.FromCardToken(token), nil
+}
+
+// Reveal returns the stored card behind a token of the account. The CVV,
+// if the customer typed it again, is attached for the charge only.
+func (s *CardService) Reveal(ctx context.Context, accountID, tokenID, cvv string) (_ domain.CreditCard, err error) {
+	ctx, span := tracer.Start(ctx, "CardService.Reveal")
+	defer func() { endSpan(span, err) }()
+
+	token, err := s.repository.FindByID(ctx, accountID, tokenID)
+	if err != nil {
+		return domain.CreditCard{}, err
+	}
+
+	number, err := s.decrypt(token)
+	if err != nil {
+		return domain.CreditCard{}, err
+	}
+
+	card := token.Card(number)
+	card.CVV = cvv
+
+	return card, nil
+}
+
+func (s *CardService) encrypt(token *domain.CardToken, number string) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return s.aead.Seal(nonce, nonce, []byte(number), additionalData(token)), nil
+}
+
+func (s *CardService) decrypt(token *domain.CardToken) (string, error) {
+	nonceSize := s.aead.NonceSize()
+	if len(token.EncryptedNumber) < nonceSize {
+		return "", errors.New("encrypted card number is too short")
+	}
+
+	nonce, sealed := token.EncryptedNumber[:nonceSize], token.EncryptedNumber[nonceSize:]
+
+	number, err := s.aead.Open(nil, nonce, sealed, additionalData(token))
+	if err != nil {
+		return "", err
+	}
+
+	return string(number), nil
+}
+
+// additionalData binds a ciphertext to its token, so it cannot be copied
+// to another row or account.
+func additionalData(token *domain.CardToken) []byte {
+	return []byte(token.AccountID + ":" + token.ID)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+func envBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// ShutdownConfig bounds the graceful shutdown of the app.
+type ShutdownConfig struct {
+	// Timeout is the overall deadline to drain requests and stop the
+	// workers.
+	Timeout time.Duration
+}
+
+// NewShutdownConfig reads SHUTDOWN_TIMEOUT.
+func NewShutdownConfig() *ShutdownConfig {
+	return &ShutdownConfig{
+		Timeout: envDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// fakeUnitOfWork runs fn against in-memory repositories. Nothing is rolled
+// back when fn fails.
+type fakeUnitOfWork struct {
+	repos *domain.Repositories
+}
+
+func (u *fakeUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos *domain.Repositories) error) error {
+	return fn(ctx, u.repos)
+}
+
+type fakeOutboxRepository struct {
+	mu       sync.Mutex
+	messages []*domain.OutboxMessage
+}
+
+func (r *fakeOutboxRepository) Save(ctx context.Context, message *domain.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *fakeOutboxRepository) ClaimPending(ctx context.Context, before time.Time, limit int, leaseUntil time.Time) ([]*domain.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var claimed []*domain.OutboxMessage
+	for _, message := range r.messages {
+		if message.Status == domain.OutboxStatusPending && !message.NextAttemptAt.After(before) && len(claimed) < limit {
+			message.NextAttemptAt = leaseUntil
+			claimed = append(claimed, message)
+		}
+	}
+
+	return claimed, nil
+}
+
+// Update has nothing to do: the relay changes the stored messages in place.
+func (r *fakeOutboxRepository) Update(ctx context.Context, message *domain.OutboxMessage) error {
+	return nil
+}
+
+// fakeWebhookRepository keeps copies of deliveries, so changes made by the
+// dispatcher only show once it stores them. Methods the dispatcher does not
+// use panic through the nil embedded interface.
+type fakeWebhookRepository struct {
+	domain.WebhookRepository
+
+	mu         sync.Mutex
+	endpoints  map[string]*domain.WebhookEndpoint
+	deliveries map[string]domain.WebhookDelivery
+	attempts   []*domain.WebhookDeliveryAttempt
+	// failAttempts makes AddAttempt fail for these delivery ids.
+	failAttempts map[string]bool
+}
+
+func newFakeWebhookRepository() *fakeWebhookRepository {
+	return &fakeWebhookRepository{
+		endpoints:    make(map[string]*domain.WebhookEndpoint),
+		deliveries:   make(map[string]domain.WebhookDelivery),
+		failAttempts: make(map[string]bool),
+	}
+}
+
+func (r *fakeWebhookRepository) FindEndpoint(ctx context.Context, accountID, id string) (*domain.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoint, ok := r.endpoints[id]
+	if !ok || endpoint.AccountID != accountID {
+		return nil, domain.ErrWebhookEndpointNotFound
+	}
+
+	return endpoint, nil
+}
+
+func (r *fakeWebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	return r.UpdateDelivery(ctx, delivery)
+}
+
+func (r *fakeWebhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *fakeWebhookRepository) ClaimDueDeliveries(ctx context.Context, before time.Time, limit int, leaseUntil time.Time) ([]*domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var claimed []*domain.WebhookDelivery
+	for id, delivery := range r.deliveries {
+		if delivery.Status != domain.WebhookDeliveryPending || delivery.NextAttemptAt.After(before) || len(claimed) == limit {
+			continue
+		}
+
+		delivery.NextAttemptAt = leaseUntil
+		r.deliveries[id] = delivery
+		claimed = append(claimed, &delivery)
+	}
+
+	return claimed, nil
+}
+
+func (r *fakeWebhookRepository) AddAttempt(ctx context.Context, attempt *domain.WebhookDeliveryAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failAttempts[attempt.DeliveryID] {
+		return errors.New("connection reset")
+	}
+
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+
+func (r *fakeWebhookRepository) delivery(id string) domain.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.deliveries[id]
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/segmentio/kafka-go"
+)
+
+type HealthServiceConfig struct {
+	// CheckTimeout bounds each dependency check.
+	CheckTimeout time.Duration
+	// MaxConsumerLag is the transaction result lag above which the gateway
+	// is reported as not ready.
+	MaxConsumerLag int64
+	// SchemaVersion is the migration version the database must be at.
+	SchemaVersion uint
+}
+
+func NewHealthServiceConfig(schemaVersion uint) *HealthServiceConfig {
+	return &HealthServiceConfig{
+		CheckTimeout:   envDuration("READINESS_CHECK_TIMEOUT", 2*time.Second),
+		MaxConsumerLag: int64(envInt("READINESS_MAX_CONSUMER_LAG", 1000)),
+		SchemaVersion:  schemaVersion,
+	}
+}
+
+// ConsumerLagReporter is implemented by KafkaConsumer.
+type ConsumerLagReporter interface {
+	Lag() int64
+}
+
+// HealthService checks the dependencies the gateway needs to serve traffic.
+type HealthService struct {
+	schemaRepository domain.SchemaRepository
+	brokers          []string
+	consumer         ConsumerLagReporter
+	config           *HealthServiceConfig
+}
+
+func NewHealthService(
+	schemaRepository domain.SchemaRepository,
+	brokers []string,
+	consumer ConsumerLagReporter,
+	config *HealthServiceConfig,
+) *HealthService {
+	return &HealthService{
+		schemaRepository: schemaRepository,
+		brokers:          brokers,
+		consumer:         consumer,
+		config:           config,
+	}
+}
+
+type healthCheck func(ctx context.Context) (map[string]any, error)
+
+// Readiness runs every check concurrently. The gateway is ready only when
+// all of them pass.
+func (s *HealthService) Readiness(ctx context.Context) *dto.ReadinessResponse {
+	checks := map[string]healthCheck{
+		"postgres":       s.checkPostgres,
+		"kafka":          s.checkKafka,
+		"kafka_consumer": s.checkConsumerLag,
+	}
+
+	response := &dto.ReadinessResponse{
+		Status: dto.HealthStatusOK,
+		Checks: make(map[string]dto.DependencyStatus, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check healthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, s.config.CheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := check(checkCtx)
+			status := dto.DependencyStatus{
+				Status:    dto.HealthStatusOK,
+				Details:   details,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = dto.HealthStatusUnavailable
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			response.Checks[name] = status
+			if err != nil {
+				response.Status = dto.HealthStatusUnavailable
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return response
+}
+
+func (s *HealthService) checkPostgres(ctx context.Context) (map[string]any, error) {
+	if err := s.schemaRepository.Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	version, dirty, err := s.schemaRepository.MigrationVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]any{
+		"migration_version":  version,
+		"expected_version":   s.config.SchemaVersion,
+		"migration_is_dirty": dirty,
+	}
+
+	if dirty {
+		return details, errors.New("last migration failed and left the schema dirty")
+	}
+	if version < s.config.SchemaVersion {
+		return details, fmt.Errorf("schema is at migration %d, expected %d", version, s.config.SchemaVersion)
+	}
+
+	return details, nil
+}
+
+// checkKafka passes when at least one broker accepts a connection.
+func (s *HealthService) checkKafka(ctx context.Context) (map[string]any, error) {
+	var errs []error
+
+	for _, broker := range s.brokers {
+		conn, err := kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn.Close()
+
+		return map[string]any{"broker": broker}, nil
+	}
+
+	return map[string]any{"brokers": s.brokers}, errors.Join(errs...)
+}
+
+func (s *HealthService) checkConsumerLag(ctx context.Context) (map[string]any, error) {
+	lag := s.consumer.Lag()
+	details := map[string]any{
+		"lag":     lag,
+		"max_lag": s.config.MaxConsumerLag,
+	}
+
+	if lag > s.config.MaxConsumerLag {
+		return details, fmt.Errorf("consumer lag %d is above %d", lag, s.config.MaxConsumerLag)
+	}
+
+	return details, nil
+}
@@ -2,144 +2,670 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain/events"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/metrics"
 )
 
+type InvoiceServiceConfig struct {
+	// IdempotencyTTL is how long an Idempotency-Key replays its response.
+	IdempotencyTTL time.Duration
+	// IdempotencyLease is how long an Idempotency-Key stays reserved for a
+	// request that has not stored its response. It must cover the slowest
+	// request.
+	IdempotencyLease time.Duration
+	// ReviewThresholds are the per-currency amounts from which invoices go
+	// to fraud analysis.
+	ReviewThresholds domain.ReviewThresholds
+	// AuthorizationTTL is how long an authorization can be captured.
+	AuthorizationTTL time.Duration
+	// AuthorizationExpiryInterval is how often expired authorizations are
+	// looked for.
+	AuthorizationExpiryInterval time.Duration
+}
+
+func NewInvoiceServiceConfig() (*InvoiceServiceConfig, error) {
+	reviewThresholds, err := domain.ParseReviewThresholds(os.Getenv("REVIEW_THRESHOLDS"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvoiceServiceConfig{
+		IdempotencyTTL:   envDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		IdempotencyLease: envDuration("IDEMPOTENCY_KEY_LEASE", time.Minute),
+		ReviewThresholds: reviewThresholds,
+		AuthorizationTTL: envDuration("AUTHORIZATION_TTL", 7*24*time.Hour),
+
+		AuthorizationExpiryInterval: envDuration("AUTHORIZATION_EXPIRY_INTERVAL", time.Minute),
+	}, nil
+}
+
 type InvoiceService struct {
 	invoiceRepository domain.InvoiceRepository
-	accountService    AccountService
-	kafkaProducer     KafkaProducerInterface
+	cardService       *CardService
+	unitOfWork        domain.UnitOfWork
+	paymentProcessor  domain.PaymentProcessor
+	config            *InvoiceServiceConfig
 }
 
 func NewInvoiceService(
 	invoiceRepository domain.InvoiceRepository,
-	accountService AccountService,
-	kafkaProducer KafkaProducerInterface,
+	cardService *CardService,
+	unitOfWork domain.UnitOfWork,
+	paymentProcessor domain.PaymentProcessor,
+	config *InvoiceServiceConfig,
 ) *InvoiceService {
 	return &InvoiceService{
 		invoiceRepository: invoiceRepository,
-		accountService:    accountService,
-		kafkaProducer:     kafkaProducer,
+		cardService:       cardService,
+		unitOfWork:        unitOfWork,
+		paymentProcessor:  paymentProcessor,
+		config:            config,
 	}
 }
 
-func (s *InvoiceService) CreateInvoice(input dto.CreateInvoiceInput) (*dto.InvoiceResponse, error) {
-	account, err := s.accountService.GetAccountByKey(input.APIKey)
+func (s *InvoiceService) CreateInvoice(ctx context.Context, input dto.CreateInvoiceInput) (_ *dto.InvoiceResponse, err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.CreateInvoice")
+	defer func() { endSpan(span, err) }()
+
+	if err := dto.Validate(&input); err != nil {
+		return nil, err
+	}
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Idempotency-Key is claimed before the card is charged, so a retry
+	// gets the stored response back instead of a second authorization. The
+	// claim is released when the request fails, letting the client retry.
+	var idempotencyKey *domain.IdempotencyKey
+	if input.IdempotencyKey != "" {
+		var replayed *dto.InvoiceResponse
+		idempotencyKey, replayed, err = s.reserveIdempotencyKey(ctx, principal.AccountID, input)
+		if err != nil || replayed != nil {
+			return replayed, err
+		}
+
+		defer func() {
+			if err != nil {
+				s.releaseIdempotencyKey(ctx, idempotencyKey)
+			}
+		}()
+	}
+
+	card, err := s.resolveCard(ctx, principal.AccountID, &input)
 	if err != nil {
 		return nil, err
 	}
 
-	invoice, err := dto.ToInvoice(&input, account.ID)
+	invoice, err := dto.ToInvoice(&input, principal.AccountID, card)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := invoice.Process(); err != nil {
+	if err := invoice.Process(ctx, s.config.ReviewThresholds, s.paymentProcessor, card, s.config.AuthorizationTTL); err != nil {
 		return nil, err
 	}
+
+	var response *dto.InvoiceResponse
+
+	// The invoice, the balance credit, the pending transaction event and the
+	// idempotency response are written atomically; the outbox relay
+	// publishes the event afterwards.
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		if err := createInvoice(ctx, repos, invoice); err != nil {
+			return err
+		}
+
+		response = dto.FromInvoice(invoice)
+		if idempotencyKey == nil {
+			return nil
+		}
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+
+		idempotencyKey.Complete(http.StatusCreated, body)
+		return repos.IdempotencyKeys.SaveResponse(ctx, idempotencyKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RecordInvoice(invoice)
+
+	return response, nil
+}
+
+// resolveCard returns the card to charge, either sent in the request or
+// taken from the vault when a card token is given.
+func (s *InvoiceService) resolveCard(ctx context.Context, accountID string, input *dto.CreateInvoiceInput) (domain.CreditCard, error) {
+	if input.CardToken == "" {
+		return dto.ToCreditCard(input), nil
+	}
+
+	if input.CardNumber != "" {
+		return domain.CreditCard{}, &domain.CardValidationError{
+			Field:   domain.CardFieldToken,
+			Message: "cannot be sent together with card_number",
+		}
+	}
+
+	card, err := s.cardService.Reveal(ctx, accountID, input.CardToken, input.CVV)
+	if errors.Is(err, domain.ErrCardTokenNotFound) {
+		return domain.CreditCard{}, &domain.CardValidationError{
+			Field:   domain.CardFieldToken,
+			Message: "card token not found",
+		}
+	}
+
+	return card, err
+}
+
+// reserveIdempotencyKey claims the request's Idempotency-Key in its own
+// transaction. When the key was already used for the same request it
+// returns the stored response to be replayed instead of a reservation, or
+// ErrIdempotencyKeyInUse while that request is still in progress.
+func (s *InvoiceService) reserveIdempotencyKey(
+	ctx context.Context,
+	accountID string,
+	input dto.CreateInvoiceInput,
+) (*domain.IdempotencyKey, *dto.InvoiceResponse, error) {
+	requestHash, err := hashRequest(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idempotencyKey, err := domain.NewIdempotencyKey(accountID, input.IdempotencyKey, requestHash, s.config.IdempotencyTTL, s.config.IdempotencyLease)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stored *domain.IdempotencyKey
+	for {
+		stored = nil
+		err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+			reserved, err := repos.IdempotencyKeys.Reserve(ctx, idempotencyKey)
+			if err != nil || reserved {
+				return err
+			}
+
+			stored, err = repos.IdempotencyKeys.FindByKey(ctx, accountID, input.IdempotencyKey)
+			return err
+		})
+		// The request holding the key failed and released it in between, so
+		// the key is free to be reserved again
+		if !errors.Is(err, domain.ErrIdempotencyKeyNotFound) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored == nil {
+		return idempotencyKey, nil, nil
+	}
+
+	if !stored.Matches(requestHash) {
+		return nil, nil, domain.ErrIdempotencyKeyMismatch
+	}
+	if !stored.Completed() {
+		return nil, nil, domain.ErrIdempotencyKeyInUse
+	}
+
+	var response dto.InvoiceResponse
+	if err := json.Unmarshal(stored.ResponseBody, &response); err != nil {
+		return nil, nil, err
+	}
+	response.Replayed = true
+
+	return nil, &response, nil
+}
+
+// releaseIdempotencyKey frees the key of a failed request. It runs even when
+// the request was cancelled, since the key would otherwise stay in use
+// until it expires.
+func (s *InvoiceService) releaseIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) {
+	err := s.unitOfWork.Do(context.WithoutCancel(ctx), func(ctx context.Context, repos *domain.Repositories) error {
+		return repos.IdempotencyKeys.Release(ctx, idempotencyKey)
+	})
+	if err != nil {
+		slog.Error("erro ao liberar idempotency key", "account_id", idempotencyKey.AccountID, "error", err)
+	}
+}
+
+func createInvoice(ctx context.Context, repos *domain.Repositories, invoice *domain.Invoice) error {
+	if err := repos.Invoices.CreateInvoice(ctx, invoice); err != nil {
+		return err
+	}
+
+	if err := enqueueInvoiceWebhook(ctx, repos, domain.WebhookEventInvoiceCreated, invoice); err != nil {
+		return err
+	}
+
 	// If status is pending needs to be processed in the fraud micro service
 	if invoice.Status == domain.StatusPending {
-		// Publish pending transaction event
-		pendingTransaction := events.NewPendingTransaction(
-			invoice.AccountID,
-			invoice.ID,
-			invoice.Amount,
-		)
-		if err := s.kafkaProducer.SendingPendingTransaction(context.Background(), *pendingTransaction); err != nil {
-			return nil, err
-		}
+		return enqueuePendingTransaction(ctx, repos.Outbox, invoice)
 	}
 
 	if invoice.Status == domain.StatusApproved {
-		_, err = s.accountService.UpdateBalance(input.APIKey, invoice.Amount)
-		if err != nil {
-			return nil, err
+		if err := creditInvoicePayment(ctx, repos, invoice); err != nil {
+			return err
 		}
 	}
 
-	err = s.invoiceRepository.CreateInvoice(invoice)
+	return enqueueInvoiceStatusWebhook(ctx, repos, invoice)
+}
+
+// hashRequest fingerprints the request body so a reused Idempotency-Key
+// can be told apart from a genuine retry.
+func hashRequest(input dto.CreateInvoiceInput) (string, error) {
+	body, err := json.Marshal(input)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return dto.FromInvoice(invoice), nil
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
 }
 
-func (s *InvoiceService) GetInvoiceByID(id, apiKey string) (*dto.InvoiceResponse, error) {
-	invoice, err := s.invoiceRepository.FindByID(id)
+func (s *InvoiceService) GetInvoiceByID(ctx context.Context, id string) (_ *dto.InvoiceResponse, err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.GetInvoiceByID")
+	defer func() { endSpan(span, err) }()
+
+	invoice, err := s.invoiceRepository.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	account, err := s.accountService.GetAccountByKey(apiKey)
+	principal, err := domain.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if invoice.AccountID != account.ID {
+	if invoice.AccountID != principal.AccountID {
 		return nil, domain.ErrUnauthorizedAccess
 	}
 
 	return dto.FromInvoice(invoice), nil
 }
 
-func (s *InvoiceService) ListInvoicesByAccount(accountID string) ([]*dto.InvoiceResponse, error) {
-	invoices, err := s.invoiceRepository.FindByAccountID(accountID)
+// ListInvoicesByAccount returns one page of the invoices of the account
+// matching filter.
+func (s *InvoiceService) ListInvoicesByAccount(ctx context.Context, accountID string, filter domain.InvoiceFilter, cursor string, limit int) (_ *dto.Page[dto.InvoiceResponse], err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.ListInvoicesByAccount")
+	defer func() { endSpan(span, err) }()
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	after, err := dto.DecodeCursor(cursor)
 	if err != nil {
 		return nil, err
 	}
 
-	response := make([]*dto.InvoiceResponse, len(invoices))
-	for i, invoice := range invoices {
-		response[i] = dto.FromInvoice(invoice)
+	limit = domain.ClampPageLimit(limit)
+
+	// Fetch one extra invoice to know whether there is a next page
+	invoices, err := s.invoiceRepository.FindByAccountID(ctx, accountID, filter, after, limit+1)
+	if err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	page := &dto.Page[dto.InvoiceResponse]{Data: make([]dto.InvoiceResponse, 0, len(invoices))}
+	if len(invoices) > limit {
+		invoices = invoices[:limit]
+		last := invoices[len(invoices)-1]
+		page.NextCursor = dto.EncodeCursor(domain.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, invoice := range invoices {
+		page.Data = append(page.Data, *dto.FromInvoice(invoice))
+	}
+
+	return page, nil
 }
 
-func (s *InvoiceService) ListByAccountAPIKey(apiKey string) ([]*dto.InvoiceResponse, error) {
-	account, err := s.accountService.GetAccountByKey(apiKey)
+// ListInvoices returns one page of the invoices of the authenticated account
+// matching filter.
+func (s *InvoiceService) ListInvoices(ctx context.Context, filter domain.InvoiceFilter, cursor string, limit int) (_ *dto.Page[dto.InvoiceResponse], err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.ListInvoices")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.ListInvoicesByAccount(account.ID)
+	return s.ListInvoicesByAccount(ctx, principal.AccountID, filter, cursor, limit)
 }
 
-// ProcessTransactionResult process transaction result after fraud analysis
-func (s *InvoiceService) ProcessTransactionResult(invoiceID string, status domain.Status) error {
-	invoice, err := s.invoiceRepository.FindByID(invoiceID)
+// ExportInvoices calls fn for every invoice of the authenticated account matching
+// filter, streaming them from the database instead of paginating.
+func (s *InvoiceService) ExportInvoices(ctx context.Context, filter domain.InvoiceFilter, fn func(*dto.InvoiceResponse) error) (err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.ExportInvoices")
+	defer func() { endSpan(span, err) }()
+
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	principal, err := domain.PrincipalFromContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	switch status {
-	case domain.StatusApproved:
-		if err := invoice.Approve(); err != nil {
+	return s.invoiceRepository.StreamByAccountID(ctx, principal.AccountID, filter, func(invoice *domain.Invoice) error {
+		return fn(dto.FromInvoice(invoice))
+	})
+}
+
+// RefundInvoice refunds all or part of an approved invoice, debiting the
+// merchant balance in the same transaction.
+func (s *InvoiceService) RefundInvoice(ctx context.Context, invoiceID string, input dto.CreateRefundInput) (_ *dto.RefundResponse, err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.RefundInvoice")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *dto.RefundResponse
+	var refunded *domain.Invoice
+
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		// Lock the invoice so concurrent refunds cannot exceed its amount
+		invoice, err := lockAccountInvoice(ctx, repos, invoiceID, principal.AccountID)
+		if err != nil {
+			return err
+		}
+
+		amount, err := dto.ToRefundAmount(input, invoice)
+		if err != nil {
+			return err
+		}
+
+		refund, err := invoice.Refund(amount)
+		if err != nil {
+			return err
+		}
+
+		if err := repos.Invoices.Update(ctx, invoice); err != nil {
+			return err
+		}
+
+		if err := repos.Refunds.Create(ctx, refund); err != nil {
+			return err
+		}
+
+		transaction, err := domain.NewRefundTransaction(invoice.AccountID, invoice.ID, refund.Amount)
+		if err != nil {
+			return err
+		}
+
+		if err := postLedgerTransaction(ctx, repos, transaction); err != nil {
+			return err
+		}
+
+		response = dto.FromRefund(refund, invoice)
+		refunded = invoice
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RecordInvoice(refunded)
+
+	return response, nil
+}
+
+// CaptureInvoice captures all or part of an authorized invoice and credits
+// the captured amount to the merchant.
+func (s *InvoiceService) CaptureInvoice(ctx context.Context, invoiceID string, input dto.CaptureInvoiceInput) (_ *dto.InvoiceResponse, err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.CaptureInvoice")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *dto.InvoiceResponse
+	var updated *domain.Invoice
+
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		invoice, err := lockAccountInvoice(ctx, repos, invoiceID, principal.AccountID)
+		if err != nil {
 			return err
 		}
-	case domain.StatusRejected:
-		if err := invoice.Reject(); err != nil {
+
+		amount, err := dto.ToCaptureAmount(input, invoice)
+		if err != nil {
 			return err
 		}
-	default:
-		return domain.ErrInvalidStatus
+
+		if err := invoice.Capture(amount); err != nil {
+			return err
+		}
+
+		if err := repos.Invoices.Update(ctx, invoice); err != nil {
+			return err
+		}
+
+		if err := creditInvoicePayment(ctx, repos, invoice); err != nil {
+			return err
+		}
+
+		if err := enqueueInvoiceStatusWebhook(ctx, repos, invoice); err != nil {
+			return err
+		}
+
+		response = dto.FromInvoice(invoice)
+		updated = invoice
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.invoiceRepository.UpdateStatus(invoice); err != nil {
-		return err
+	metrics.RecordInvoice(updated)
+
+	return response, nil
+}
+
+// VoidInvoice cancels an authorization before it is captured.
+func (s *InvoiceService) VoidInvoice(ctx context.Context, invoiceID string) (_ *dto.InvoiceResponse, err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.VoidInvoice")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *dto.InvoiceResponse
+	var updated *domain.Invoice
+
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		invoice, err := lockAccountInvoice(ctx, repos, invoiceID, principal.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if err := invoice.Void(); err != nil {
+			return err
+		}
+
+		if err := repos.Invoices.Update(ctx, invoice); err != nil {
+			return err
+		}
+
+		response = dto.FromInvoice(invoice)
+		updated = invoice
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if status == domain.StatusApproved {
-		account, err := s.accountService.GetAccountByID(invoice.AccountID)
+
+	metrics.RecordInvoice(updated)
+
+	return response, nil
+}
+
+// ExpireAuthorizations marks as expired one batch of authorizations whose
+// capture window has passed and returns how many were expired.
+func (s *InvoiceService) ExpireAuthorizations(ctx context.Context, now time.Time, limit int) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.ExpireAuthorizations")
+	defer func() { endSpan(span, err) }()
+
+	var expired []*domain.Invoice
+
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		invoices, err := repos.Invoices.FindExpiredAuthorizations(ctx, now, limit)
 		if err != nil {
 			return err
 		}
-		if _, err := s.accountService.UpdateBalance(account.APIKey, invoice.Amount); err != nil {
+
+		for _, invoice := range invoices {
+			if err := invoice.Expire(now); err != nil {
+				return err
+			}
+
+			if err := repos.Invoices.Update(ctx, invoice); err != nil {
+				return err
+			}
+
+			expired = append(expired, invoice)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, invoice := range expired {
+		metrics.RecordInvoice(invoice)
+	}
+
+	return len(expired), nil
+}
+
+// ProcessTransactionResult process transaction result after fraud analysis.
+// Results are delivered at least once: a result the invoice already has is
+// acknowledged without changes.
+func (s *InvoiceService) ProcessTransactionResult(ctx context.Context, invoiceID string, status domain.Status) (err error) {
+	ctx, span := tracer.Start(ctx, "InvoiceService.ProcessTransactionResult")
+	defer func() { endSpan(span, err) }()
+
+	var processed *domain.Invoice
+
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		invoice, err := repos.Invoices.FindByIDForUpdate(ctx, invoiceID)
+		if err != nil {
 			return err
 		}
+
+		if invoice.HasOutcome(status) {
+			slog.Info("resultado da transação já processado", "invoice_id", invoice.ID, "status", status)
+			return nil
+		}
+
+		switch status {
+		case domain.StatusApproved:
+			if err := invoice.Approve(s.config.AuthorizationTTL); err != nil {
+				return err
+			}
+		case domain.StatusRejected:
+			if err := invoice.Reject(); err != nil {
+				return err
+			}
+		default:
+			return domain.ErrInvalidStatus
+		}
+
+		if err := repos.Invoices.Update(ctx, invoice); err != nil {
+			return err
+		}
+
+		// Authorized invoices are only credited once captured
+		if invoice.Status == domain.StatusApproved {
+			if err := creditInvoicePayment(ctx, repos, invoice); err != nil {
+				return err
+			}
+		}
+
+		processed = invoice
+		return enqueueInvoiceStatusWebhook(ctx, repos, invoice)
+	})
+	if err != nil || processed == nil {
+		return err
 	}
+
+	metrics.RecordInvoice(processed)
+	metrics.ObserveInvoiceResult(processed, time.Now())
+
 	return nil
 }
+
+// lockAccountInvoice loads and locks an invoice, making sure it belongs to
+// the given account.
+func lockAccountInvoice(ctx context.Context, repos *domain.Repositories, invoiceID, accountID string) (*domain.Invoice, error) {
+	invoice, err := repos.Invoices.FindByIDForUpdate(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if invoice.AccountID != accountID {
+		return nil, domain.ErrUnauthorizedAccess
+	}
+
+	return invoice, nil
+}
+
+func enqueuePendingTransaction(ctx context.Context, outbox domain.OutboxRepository, invoice *domain.Invoice) error {
+	pendingTransaction := events.NewPendingTransaction(
+		invoice.AccountID,
+		invoice.ID,
+		invoice.Amount,
+	)
+
+	payload, err := json.Marshal(pendingTransaction)
+	if err != nil {
+		return err
+	}
+
+	message := domain.NewOutboxMessage(invoice.ID, events.PendingTransactionEvent, payload)
+	message.TraceContext = injectTraceContext(ctx)
+
+	return outbox.Save(ctx, message)
+}
+
+// creditInvoicePayment records the captured amount of an approved invoice in
+// the ledger, crediting the merchant balance.
+func creditInvoicePayment(ctx context.Context, repos *domain.Repositories, invoice *domain.Invoice) error {
+	transaction, err := domain.NewInvoicePaymentTransaction(invoice.AccountID, invoice.ID, invoice.CapturedAmount)
+	if err != nil {
+		return err
+	}
+
+	return postLedgerTransaction(ctx, repos, transaction)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers added to messages sent to the dead-letter topic, next to the
+// headers of the original message.
+const (
+	DeadLetterErrorHeader     = "dlq-error"
+	DeadLetterAttemptsHeader  = "dlq-attempts"
+	DeadLetterTopicHeader     = "dlq-original-topic"
+	DeadLetterPartitionHeader = "dlq-original-partition"
+	DeadLetterOffsetHeader    = "dlq-original-offset"
+	DeadLetterGroupHeader     = "dlq-consumer-group"
+	DeadLetterFailedAtHeader  = "dlq-failed-at"
+)
+
+// DeadLetterTopic returns the dead-letter topic for topic, read from
+// KAFKA_TRANSACTIONS_RESULT_DLQ_TOPIC or defaulting to "<topic>_dlq".
+func DeadLetterTopic(topic string) string {
+	if dlqTopic := os.Getenv("KAFKA_TRANSACTIONS_RESULT_DLQ_TOPIC"); dlqTopic != "" {
+		return dlqTopic
+	}
+
+	return topic + "_dlq"
+}
+
+// DeadLetterPublisher parks messages that could not be processed.
+type DeadLetterPublisher interface {
+	Publish(ctx context.Context, msg kafka.Message, cause error, attempts int) error
+	Close() error
+}
+
+type KafkaDeadLetterProducer struct {
+	writer  *kafka.Writer
+	topic   string
+	groupID string
+}
+
+func NewKafkaDeadLetterProducer(config *KafkaConfig, groupID string) *KafkaDeadLetterProducer {
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(config.Brokers...),
+		Topic:    config.Topic,
+		Balancer: &kafka.LeastBytes{},
+	}
+
+	slog.Info("kafka dead letter producer iniciado", "brokers", config.Brokers, "topic", config.Topic)
+	return &KafkaDeadLetterProducer{
+		writer:  writer,
+		topic:   config.Topic,
+		groupID: groupID,
+	}
+}
+
+// Publish sends the original key, value and headers to the dead-letter
+// topic along with headers describing the failure.
+func (p *KafkaDeadLetterProducer) Publish(ctx context.Context, msg kafka.Message, cause error, attempts int) error {
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())},
+		kafka.Header{Key: DeadLetterAttemptsHeader, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(msg.Topic)},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: DeadLetterGroupHeader, Value: []byte(p.groupID)},
+		kafka.Header{Key: DeadLetterFailedAtHeader, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	err := p.writer.WriteMessages(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers})
+	metrics.RecordKafkaProduce(p.topic, err)
+	if err != nil {
+		return err
+	}
+
+	slog.Warn("mensagem enviada para a dead letter queue",
+		"topic", p.topic,
+		"original_topic", msg.Topic,
+		"offset", msg.Offset,
+		"attempts", attempts,
+		"error", cause)
+	return nil
+}
+
+func (p *KafkaDeadLetterProducer) Close() error {
+	return p.writer.Close()
+}
+
+// DeadLetterReplayer moves messages from the dead-letter topic back to the
+// topic they came from, without the dead-letter headers.
+type DeadLetterReplayer struct {
+	reader *kafka.Reader
+	writer *kafka.Writer
+}
+
+func NewDeadLetterReplayer(dlqConfig *KafkaConfig, targetConfig *KafkaConfig, groupID string) *DeadLetterReplayer {
+	return &DeadLetterReplayer{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: dlqConfig.Brokers,
+			Topic:   dlqConfig.Topic,
+			GroupID: groupID,
+		}),
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(targetConfig.Brokers...),
+			Topic:    targetConfig.Topic,
+			Balancer: &kafka.LeastBytes{},
+		},
+	}
+}
+
+// Replay republishes dead-lettered messages until none arrives for idle
+// or limit messages were replayed (0 means no limit). The offset of each
+// message is committed only after it was republished.
+func (r *DeadLetterReplayer) Replay(ctx context.Context, idle time.Duration, limit int) (int, error) {
+	replayed := 0
+
+	for limit == 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, idle)
+		msg, err := r.reader.FetchMessage(fetchCtx)
+		cancel()
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return replayed, nil
+			}
+			return replayed, err
+		}
+
+		if err := r.writer.WriteMessages(ctx, kafka.Message{
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: withoutDeadLetterHeaders(msg.Headers),
+		}); err != nil {
+			return replayed, err
+		}
+
+		if err := r.reader.CommitMessages(ctx, msg); err != nil {
+			return replayed, err
+		}
+
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func (r *DeadLetterReplayer) Close() error {
+	return errors.Join(r.reader.Close(), r.writer.Close())
+}
+
+func withoutDeadLetterHeaders(headers []kafka.Header) []kafka.Header {
+	var kept []kafka.Header
+	for _, header := range headers {
+		if !strings.HasPrefix(header.Key, "dlq-") {
+			kept = append(kept, header)
+		}
+	}
+
+	return kept
+}
@@ -3,20 +3,38 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
-	"time" // Add time import
+	"time"
 
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain/events"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/metrics"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type KafkaProducerInterface interface {
-	SendingPendingTransaction(ctx context.Context, event events.PendingTransaction) error
+	// SendPendingTransactions publishes the messages in a single batch. When
+	// only some of them fail, the error is a kafka.WriteErrors holding the
+	// error of each message by index.
+	SendPendingTransactions(ctx context.Context, messages []PendingTransactionMessage) error
 	Close() error
 }
 
+// PendingTransactionMessage is a pending transaction event and the trace
+// context it is published in.
+type PendingTransactionMessage struct {
+	Event        events.PendingTransaction
+	TraceContext map[string]string
+}
+
 type KafkaConsumerInterface interface {
 	Consume(ctx context.Context) error
 	Close() error
@@ -64,6 +82,9 @@ func NewKafkaProducer(config *KafkaConfig) *KafkaProducer {
 		Addr:     kafka.TCP(config.Brokers...),
 		Topic:    config.Topic,
 		Balancer: &kafka.LeastBytes{},
+		// Batches are handed over whole, so waiting for more messages only
+		// delays them
+		BatchTimeout: 5 * time.Millisecond,
 	}
 
 	slog.Info("kafka producer iniciado", "brokers", config.Brokers, "topic", config.Topic)
@@ -74,44 +95,109 @@ func NewKafkaProducer(config *KafkaConfig) *KafkaProducer {
 	}
 }
 
-func (s *KafkaProducer) SendingPendingTransaction(ctx context.Context, event events.PendingTransaction) error {
-	value, err := json.Marshal(event)
-	if err != nil {
-		slog.Error("erro ao converter evento para json", "error", err)
-		return err
+func (s *KafkaProducer) SendPendingTransactions(ctx context.Context, messages []PendingTransactionMessage) error {
+	msgs := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		value, err := json.Marshal(message.Event)
+		if err != nil {
+			slog.Error("erro ao converter evento para json", "error", err)
+			return err
+		}
+
+		msgs[i] = kafka.Message{Value: value}
 	}
 
-	msg := kafka.Message{
-		Value: value,
+	spans := make([]trace.Span, len(messages))
+	for i, message := range messages {
+		var spanCtx context.Context
+		spanCtx, spans[i] = tracer.Start(extractTraceContext(ctx, message.TraceContext), s.topic+" publish",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "kafka"),
+				attribute.String("messaging.destination.name", s.topic),
+				attribute.String("invoice_id", message.Event.InvoiceID),
+			),
+		)
+
+		// The anti-fraud service copies the trace context to its result, so
+		// the round trip is a single trace
+		otel.GetTextMapPropagator().Inject(spanCtx, kafkaHeaderCarrier{headers: &msgs[i].Headers})
 	}
 
-	slog.Info("enviando mensagem para o kafka",
+	slog.Info("enviando mensagens para o kafka",
 		"topic", s.topic,
-		"message", string(value))
+		"count", len(msgs))
 
-	if err := s.writer.WriteMessages(ctx, msg); err != nil {
-		slog.Error("erro ao enviar mensagem para o kafka", "error", err)
+	err := s.writer.WriteMessages(ctx, msgs...)
+	for i, span := range spans {
+		messageErr := writeError(err, i)
+		metrics.RecordKafkaProduce(s.topic, messageErr)
+		endSpan(span, messageErr)
+	}
+	if err != nil {
+		slog.Error("erro ao enviar mensagens para o kafka", "error", err)
 		return err
 	}
 
-	slog.Info("mensagem enviada com sucesso para o kafka", "topic", s.topic)
+	slog.Info("mensagens enviadas com sucesso para o kafka", "topic", s.topic, "count", len(msgs))
 	return nil
 }
 
+// writeError returns the error of the i-th message of a batch written with
+// err: its own error when the writer reported errors per message, or err
+// itself when the whole batch failed.
+func writeError(err error, i int) error {
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) && i < len(writeErrors) {
+		return writeErrors[i]
+	}
+
+	return err
+}
+
 func (s *KafkaProducer) Close() error {
 	slog.Info("fechando conexao com o kafka")
 	return s.writer.Close()
 }
 
+// KafkaConsumerConfig is the retry policy for transaction results.
+type KafkaConsumerConfig struct {
+	// MaxAttempts bounds how many times a message is processed before it is
+	// sent to the dead-letter topic.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewKafkaConsumerConfig() *KafkaConsumerConfig {
+	return &KafkaConsumerConfig{
+		MaxAttempts: envInt("KAFKA_CONSUMER_MAX_ATTEMPTS", 5),
+		BaseBackoff: envDuration("KAFKA_CONSUMER_BASE_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:  envDuration("KAFKA_CONSUMER_MAX_BACKOFF", 30*time.Second),
+	}
+}
+
+// errMalformedMessage marks messages that will never be processed, so they
+// go to the dead-letter topic without retries.
+var errMalformedMessage = errors.New("malformed transaction result")
+
 type KafkaConsumer struct {
 	reader         *kafka.Reader
 	topic          string
 	brokers        []string
 	groupID        string
 	invoiceService *InvoiceService
+	deadLetter     DeadLetterPublisher
+	config         *KafkaConsumerConfig
 }
 
-func NewKafkaConsumer(config *KafkaConfig, groupID string, invoiceService *InvoiceService) *KafkaConsumer {
+func NewKafkaConsumer(
+	config *KafkaConfig,
+	groupID string,
+	invoiceService *InvoiceService,
+	deadLetter DeadLetterPublisher,
+	consumerConfig *KafkaConsumerConfig,
+) *KafkaConsumer {
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers: config.Brokers,
 		Topic:   config.Topic,
@@ -129,44 +215,177 @@ func NewKafkaConsumer(config *KafkaConfig, groupID string, invoiceService *Invoi
 		brokers:        config.Brokers,
 		groupID:        groupID,
 		invoiceService: invoiceService,
+		deadLetter:     deadLetter,
+		config:         consumerConfig,
 	}
 }
 
 func (c *KafkaConsumer) Consume(ctx context.Context) error {
+	readFailures := 0
+
 	for {
-		msg, err := c.reader.ReadMessage(ctx)
+		// Offsets are committed by hand once the message was handled, so a
+		// crash before that makes Kafka deliver it again
+		msg, err := c.reader.FetchMessage(ctx)
 		if err != nil {
-			// Log error, but continue loop to retry connection/reading
-			slog.Error("erro ao ler mensagem do kafka, retrying...", "error", err)
-			// Optional: Add a small delay before retrying
-			time.Sleep(5 * time.Second)
-			continue // Continue to the next iteration to retry reading
-		}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			metrics.RecordKafkaConsumeError(c.topic, metrics.ConsumeStageFetch)
+			delay := c.backoff(readFailures)
+			readFailures++
+			slog.Error("erro ao ler mensagem do kafka, retrying...", "error", err, "retry_in", delay)
 
-		var result events.TransactionResult
-		if err := json.Unmarshal(msg.Value, &result); err != nil {
-			slog.Error("erro ao converter mensagem para TransactionResult", "error", err)
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
 			continue
 		}
+		readFailures = 0
+		metrics.RecordKafkaConsume(c.topic)
 
-		slog.Info("mensagem recebida do kafka",
-			"topic", c.topic,
-			"invoice_id", result.InvoiceID,
-			"status", result.Status)
-
-		// Process result
-		if err := c.invoiceService.ProcessTransactionResult(result.InvoiceID, result.ToDomainStatus()); err != nil {
-			slog.Error("erro ao processar resultado da transação",
-				"error", err,
-				"invoice_id", result.InvoiceID,
-				"status", result.Status)
-			continue
+		if err := c.handle(ctx, msg); err != nil {
+			return err
+		}
+
+		// The message was handled, so commit it even when shutting down
+		if err := c.reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			// Processing is idempotent, so a redelivery after a failed commit is harmless
+			metrics.RecordKafkaConsumeError(c.topic, metrics.ConsumeStageCommit)
+			slog.Error("erro ao confirmar offset no kafka", "error", err, "offset", msg.Offset)
+		}
+
+		// Stop between messages once shutdown was requested
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// handle processes a message, retrying transient failures, and parks it in
+// the dead-letter topic when it cannot be processed. It only returns an
+// error when ctx is cancelled, in which case the offset must not be
+// committed.
+func (c *KafkaConsumer) handle(ctx context.Context, msg kafka.Message) error {
+	// Continue the trace started by the request that created the invoice
+	ctx = otel.GetTextMapPropagator().Extract(ctx, kafkaHeaderCarrier{headers: &msg.Headers})
+	ctx, span := tracer.Start(ctx, c.topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", c.topic),
+			attribute.String("messaging.consumer.group.name", c.groupID),
+			attribute.Int("messaging.destination.partition.id", msg.Partition),
+			attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+		),
+	)
+	defer span.End()
+
+	attempts, err := c.processWithRetry(ctx, msg)
+	span.SetAttributes(attribute.Int("attempts", attempts))
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	metrics.RecordKafkaConsumeError(c.topic, metrics.ConsumeStageProcess)
+	slog.Error("erro ao processar resultado da transação",
+		"error", err,
+		"offset", msg.Offset,
+		"attempts", attempts)
+
+	// Keep trying the dead-letter topic; moving on would lose the message
+	for failures := 0; ; failures++ {
+		publishErr := c.deadLetter.Publish(ctx, msg, err, attempts)
+		if publishErr == nil {
+			return nil
 		}
 
-		slog.Info("transação processada com sucesso",
-			"invoice_id", result.InvoiceID,
-			"status", result.Status)
+		delay := c.backoff(failures)
+		slog.Error("erro ao enviar mensagem para a dead letter queue", "error", publishErr, "retry_in", delay)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *KafkaConsumer) processWithRetry(ctx context.Context, msg kafka.Message) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := c.process(ctx, msg)
+		if err == nil || isPermanentConsumerError(err) || attempt >= c.config.MaxAttempts {
+			return attempt, err
+		}
+
+		delay := c.backoff(attempt - 1)
+		slog.Warn("falha ao processar resultado da transação, tentando novamente",
+			"error", err,
+			"attempt", attempt,
+			"retry_in", delay)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return attempt, err
+		}
+	}
+}
+
+func (c *KafkaConsumer) process(ctx context.Context, msg kafka.Message) error {
+	var result events.TransactionResult
+	if err := json.Unmarshal(msg.Value, &result); err != nil {
+		return fmt.Errorf("%w: %v", errMalformedMessage, err)
 	}
+
+	slog.Info("mensagem recebida do kafka",
+		"topic", c.topic,
+		"invoice_id", result.InvoiceID,
+		"status", result.Status)
+
+	// A result being processed is saved even if shutdown was requested
+	if err := c.invoiceService.ProcessTransactionResult(context.WithoutCancel(ctx), result.InvoiceID, result.ToDomainStatus()); err != nil {
+		return err
+	}
+
+	slog.Info("transação processada com sucesso",
+		"invoice_id", result.InvoiceID,
+		"status", result.Status)
+	return nil
+}
+
+func (c *KafkaConsumer) backoff(attempts int) time.Duration {
+	return exponentialBackoff(c.config.BaseBackoff, c.config.MaxBackoff, attempts)
+}
+
+// isPermanentConsumerError reports whether retrying the message cannot
+// help.
+func isPermanentConsumerError(err error) bool {
+	return errors.Is(err, errMalformedMessage) ||
+		errors.Is(err, domain.ErrInvalidStatus) ||
+		errors.Is(err, domain.ErrInvoiceNotFound)
+}
+
+// sleepContext waits for d or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Lag returns how many messages the consumer is behind the end of its
+// partition, as of the last fetch.
+func (c *KafkaConsumer) Lag() int64 {
+	return c.reader.Stats().Lag
 }
 
 func (c *KafkaConsumer) Close() error {
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// postLedgerTransaction appends a balanced ledger transaction and applies its
+// effect to the cached merchant balances. It must run inside the same unit of
+// work as the business change it records.
+func postLedgerTransaction(ctx context.Context, repos *domain.Repositories, transaction *domain.LedgerTransaction) error {
+	if err := repos.Ledger.Append(ctx, transaction); err != nil {
+		return err
+	}
+
+	for _, change := range transaction.MerchantBalanceChanges() {
+		if change.IsZero() {
+			continue
+		}
+
+		if _, err := repos.Accounts.AddBalance(ctx, transaction.AccountID, change); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
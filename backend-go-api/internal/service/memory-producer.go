@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain/events"
+)
+
+// InMemoryKafkaProducer is a KafkaProducerInterface that keeps published
+// events in memory. It is meant for tests and local runs without a broker.
+type InMemoryKafkaProducer struct {
+	mu                  sync.Mutex
+	PendingTransactions []events.PendingTransaction
+	// Err, when set, is returned by every send instead of recording the events.
+	Err error
+}
+
+func NewInMemoryKafkaProducer() *InMemoryKafkaProducer {
+	return &InMemoryKafkaProducer{}
+}
+
+func (p *InMemoryKafkaProducer) SendPendingTransactions(ctx context.Context, messages []PendingTransactionMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return p.Err
+	}
+
+	for _, message := range messages {
+		p.PendingTransactions = append(p.PendingTransactions, message.Event)
+	}
+	return nil
+}
+
+// Sent returns a copy of the pending transactions published so far.
+func (p *InMemoryKafkaProducer) Sent() []events.PendingTransaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]events.PendingTransaction(nil), p.PendingTransactions...)
+}
+
+func (p *InMemoryKafkaProducer) Close() error {
+	return nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// PublishTimeout bounds publishing one batch to Kafka.
+	PublishTimeout time.Duration
+	// Lease is how long claimed messages are reserved for the relay that
+	// claimed them. It must cover publishing the batch.
+	Lease time.Duration
+}
+
+func NewOutboxRelayConfig() *OutboxRelayConfig {
+	config := &OutboxRelayConfig{
+		PollInterval:   envDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		BatchSize:      envInt("OUTBOX_BATCH_SIZE", 100),
+		MaxAttempts:    envInt("OUTBOX_MAX_ATTEMPTS", 10),
+		BaseBackoff:    envDuration("OUTBOX_BASE_BACKOFF", time.Second),
+		MaxBackoff:     envDuration("OUTBOX_MAX_BACKOFF", 5*time.Minute),
+		PublishTimeout: envDuration("OUTBOX_PUBLISH_TIMEOUT", 10*time.Second),
+	}
+
+	// The whole batch is published at once
+	config.Lease = envDuration("OUTBOX_LEASE", 2*config.PublishTimeout)
+
+	return config
+}
+
+// OutboxRelay publishes messages written to the outbox table to Kafka and
+// marks them as sent. Delivery is at-least-once: a crash between the publish
+// and recording it makes the message go out again when its lease ends.
+type OutboxRelay struct {
+	unitOfWork domain.UnitOfWork
+	producer   KafkaProducerInterface
+	config     *OutboxRelayConfig
+}
+
+func NewOutboxRelay(unitOfWork domain.UnitOfWork, producer KafkaProducerInterface, config *OutboxRelayConfig) *OutboxRelay {
+	return &OutboxRelay{
+		unitOfWork: unitOfWork,
+		producer:   producer,
+		config:     config,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// The current batch is finished even if shutdown was requested
+		if _, err := r.RelayBatch(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("erro ao publicar mensagens do outbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch claims one batch of due messages, publishes them and returns
+// how many were sent successfully. The messages are published in a single
+// Kafka batch outside of any transaction, and their outcomes are recorded
+// together in a second short transaction.
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	var messages []*domain.OutboxMessage
+
+	now := time.Now()
+	err := r.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		var err error
+		messages, err = repos.Outbox.ClaimPending(ctx, now, r.config.BatchSize, now.Add(r.config.Lease))
+		return err
+	})
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	errs := r.publish(ctx, messages)
+
+	sent := 0
+	for i, message := range messages {
+		if errs[i] == nil {
+			message.MarkSent()
+			sent++
+			continue
+		}
+
+		giveUp := message.Attempts+1 >= r.config.MaxAttempts
+		message.MarkFailed(errs[i], time.Now().Add(r.backoff(message.Attempts)), giveUp)
+
+		slog.Error("erro ao publicar mensagem do outbox",
+			"error", errs[i],
+			"outbox_id", message.ID,
+			"event_type", message.EventType,
+			"attempts", message.Attempts,
+			"give_up", giveUp)
+	}
+
+	err = r.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		for _, message := range messages {
+			if err := repos.Outbox.Update(ctx, message); err != nil {
+				return fmt.Errorf("message %s: %w", message.ID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return sent, nil
+}
+
+// publish sends the messages to Kafka in one batch and returns the error of
+// each message by index.
+func (r *OutboxRelay) publish(ctx context.Context, messages []*domain.OutboxMessage) []error {
+	errs := make([]error, len(messages))
+	spans := make([]trace.Span, len(messages))
+	defer func() {
+		for i, span := range spans {
+			if span != nil {
+				endSpan(span, errs[i])
+			}
+		}
+	}()
+
+	var batch []PendingTransactionMessage
+	var batched []int
+	for i, message := range messages {
+		// Publishing belongs to the trace of the request that wrote the message
+		var spanCtx context.Context
+		spanCtx, spans[i] = tracer.Start(extractTraceContext(ctx, message.TraceContext), "OutboxRelay.publish", trace.WithAttributes(
+			attribute.String("outbox_id", message.ID),
+			attribute.String("event_type", message.EventType),
+			attribute.Int("attempts", message.Attempts),
+		))
+
+		switch message.EventType {
+		case events.PendingTransactionEvent:
+			var event events.PendingTransaction
+			if err := json.Unmarshal(message.Payload, &event); err != nil {
+				errs[i] = err
+				continue
+			}
+
+			batch = append(batch, PendingTransactionMessage{Event: event, TraceContext: injectTraceContext(spanCtx)})
+			batched = append(batched, i)
+		default:
+			errs[i] = fmt.Errorf("unknown outbox event type %q", message.EventType)
+		}
+	}
+
+	if len(batch) == 0 {
+		return errs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.PublishTimeout)
+	defer cancel()
+
+	err := r.producer.SendPendingTransactions(ctx, batch)
+	for j, i := range batched {
+		errs[i] = writeError(err, j)
+	}
+
+	return errs
+}
+
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	return exponentialBackoff(r.config.BaseBackoff, r.config.MaxBackoff, attempts)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain/events"
+	"github.com/segmentio/kafka-go"
+)
+
+func newTestOutboxRelay(t *testing.T, maxAttempts int) (*OutboxRelay, *fakeOutboxRepository, *InMemoryKafkaProducer) {
+	t.Helper()
+
+	outbox := &fakeOutboxRepository{}
+	producer := NewInMemoryKafkaProducer()
+	relay := NewOutboxRelay(&fakeUnitOfWork{repos: &domain.Repositories{Outbox: outbox}}, producer, &OutboxRelayConfig{
+		BatchSize:      10,
+		MaxAttempts:    maxAttempts,
+		BaseBackoff:    time.Second,
+		MaxBackoff:     time.Minute,
+		PublishTimeout: time.Second,
+		Lease:          time.Minute,
+	})
+
+	return relay, outbox, producer
+}
+
+func savePendingTransaction(t *testing.T, outbox *fakeOutboxRepository, invoiceID string) *domain.OutboxMessage {
+	t.Helper()
+
+	payload, err := json.Marshal(events.NewPendingTransaction("account-1", invoiceID, domain.NewMoney(1050, domain.DefaultCurrency)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := domain.NewOutboxMessage(invoiceID, events.PendingTransactionEvent, payload)
+	outbox.Save(context.Background(), message)
+
+	return message
+}
+
+func TestOutboxRelayPublishesPendingMessages(t *testing.T) {
+	relay, outbox, producer := newTestOutboxRelay(t, 3)
+	first := savePendingTransaction(t, outbox, "invoice-1")
+	second := savePendingTransaction(t, outbox, "invoice-2")
+
+	sent, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Fatalf("sent = %d, want 2", sent)
+	}
+
+	published := producer.Sent()
+	if len(published) != 2 || published[0].InvoiceID != "invoice-1" || published[1].InvoiceID != "invoice-2" {
+		t.Fatalf("published = %+v, want invoice-1 and invoice-2", published)
+	}
+	if published[0].Amount.Amount != 1050 || published[0].Currency != domain.DefaultCurrency {
+		t.Errorf("amount = %v %s, want 10.50 %s", published[0].Amount, published[0].Currency, domain.DefaultCurrency)
+	}
+
+	for _, message := range []*domain.OutboxMessage{first, second} {
+		if message.Status != domain.OutboxStatusSent || message.SentAt == nil {
+			t.Errorf("message %s: status = %s, sent at %v, want sent", message.AggregateID, message.Status, message.SentAt)
+		}
+	}
+
+	// Sent messages are not published again
+	if sent, err := relay.RelayBatch(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("second batch: sent = %d, err = %v, want nothing", sent, err)
+	}
+	if len(producer.Sent()) != 2 {
+		t.Errorf("published %d events, want 2", len(producer.Sent()))
+	}
+}
+
+func TestOutboxRelayRetriesWithBackoff(t *testing.T) {
+	relay, outbox, producer := newTestOutboxRelay(t, 5)
+	message := savePendingTransaction(t, outbox, "invoice-1")
+	producer.Err = errors.New("broker unavailable")
+
+	before := time.Now()
+	sent, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 0 {
+		t.Fatalf("sent = %d, want 0", sent)
+	}
+	if message.Status != domain.OutboxStatusPending || message.Attempts != 1 || message.LastError != "broker unavailable" {
+		t.Fatalf("message = %+v, want pending after 1 failed attempt", message)
+	}
+	if delay := message.NextAttemptAt.Sub(before); delay < time.Second || delay > 2*time.Second {
+		t.Fatalf("first retry in %v, want about 1s", delay)
+	}
+
+	// The message is not due yet
+	producer.Err = nil
+	if sent, err := relay.RelayBatch(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("batch before the retry time: sent = %d, err = %v, want nothing", sent, err)
+	}
+
+	producer.Err = errors.New("broker unavailable")
+	message.NextAttemptAt = time.Now()
+	before = time.Now()
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if delay := message.NextAttemptAt.Sub(before); delay < 2*time.Second || delay > 3*time.Second {
+		t.Fatalf("second retry in %v, want about 2s", delay)
+	}
+
+	producer.Err = nil
+	message.NextAttemptAt = time.Now()
+	if sent, err := relay.RelayBatch(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("batch after recovery: sent = %d, err = %v, want 1", sent, err)
+	}
+	if message.Status != domain.OutboxStatusSent || message.LastError != "" {
+		t.Errorf("message = %+v, want sent", message)
+	}
+}
+
+func TestOutboxRelayGivesUpAfterMaxAttempts(t *testing.T) {
+	relay, outbox, producer := newTestOutboxRelay(t, 2)
+	message := savePendingTransaction(t, outbox, "invoice-1")
+	producer.Err = errors.New("broker unavailable")
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		message.NextAttemptAt = time.Now()
+		if _, err := relay.RelayBatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if message.Attempts != attempt {
+			t.Fatalf("attempts = %d, want %d", message.Attempts, attempt)
+		}
+	}
+
+	if message.Status != domain.OutboxStatusFailed {
+		t.Fatalf("status = %s, want failed", message.Status)
+	}
+
+	producer.Err = nil
+	message.NextAttemptAt = time.Now()
+	if sent, err := relay.RelayBatch(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("batch after giving up: sent = %d, err = %v, want nothing", sent, err)
+	}
+	if len(producer.Sent()) != 0 {
+		t.Errorf("published %d events, want none", len(producer.Sent()))
+	}
+}
+
+func TestOutboxRelayRecordsErrorsPerMessage(t *testing.T) {
+	relay, outbox, producer := newTestOutboxRelay(t, 3)
+	first := savePendingTransaction(t, outbox, "invoice-1")
+	second := savePendingTransaction(t, outbox, "invoice-2")
+	producer.Err = kafka.WriteErrors{nil, errors.New("leader not available")}
+
+	sent, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 1 {
+		t.Fatalf("sent = %d, want 1", sent)
+	}
+	if first.Status != domain.OutboxStatusSent {
+		t.Errorf("first message: status = %s, want sent", first.Status)
+	}
+	if second.Status != domain.OutboxStatusPending || second.Attempts != 1 || second.LastError != "leader not available" {
+		t.Errorf("second message = %+v, want pending after 1 failed attempt", second)
+	}
+}
+
+func TestOutboxRelaySkipsLeasedMessages(t *testing.T) {
+	relay, outbox, producer := newTestOutboxRelay(t, 3)
+	message := savePendingTransaction(t, outbox, "invoice-1")
+
+	// Another relay claimed the message and has not recorded the outcome yet
+	claimed, err := outbox.ClaimPending(context.Background(), time.Now(), 10, time.Now().Add(time.Minute))
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claimed %d messages, err = %v, want 1", len(claimed), err)
+	}
+
+	if sent, err := relay.RelayBatch(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("batch during the lease: sent = %d, err = %v, want nothing", sent, err)
+	}
+	if len(producer.Sent()) != 0 {
+		t.Fatalf("published %d events during the lease, want none", len(producer.Sent()))
+	}
+
+	// The lease ended without an outcome, so the message is published again
+	message.NextAttemptAt = time.Now()
+	if sent, err := relay.RelayBatch(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("batch after the lease: sent = %d, err = %v, want 1", sent, err)
+	}
+}
+
+func TestOutboxRelayParksUnknownEventTypes(t *testing.T) {
+	relay, outbox, _ := newTestOutboxRelay(t, 1)
+	message := domain.NewOutboxMessage("invoice-1", "unknown_event", []byte(`{}`))
+	outbox.Save(context.Background(), message)
+
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if message.Status != domain.OutboxStatusFailed {
+		t.Errorf("status = %s, want failed", message.Status)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{5, 32 * time.Second},
+		{6, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := exponentialBackoff(time.Second, time.Minute, tt.attempts); got != tt.want {
+			t.Errorf("exponentialBackoff(1s, 1m, %d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// Magic card numbers understood by SimulatedPaymentProcessor. Any other
+// number is approved.
+const (
+	SimulatorCardDeclined          = "4000000000000002"
+	SimulatorCardInsufficientFunds = "4000000000009995"
+	SimulatorCardTimeout           = "4000000000000119"
+)
+
+// SimulatedPaymentProcessor is a deterministic domain.PaymentProcessor for
+// tests and local runs. The outcome depends only on the card number.
+type SimulatedPaymentProcessor struct{}
+
+func NewSimulatedPaymentProcessor() *SimulatedPaymentProcessor {
+	return &SimulatedPaymentProcessor{}
+}
+
+func (p *SimulatedPaymentProcessor) Authorize(ctx context.Context, request domain.PaymentRequest) (_ domain.PaymentDecision, err error) {
+	_, span := tracer.Start(ctx, "SimulatedPaymentProcessor.Authorize")
+	defer func() { endSpan(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	switch domain.NormalizeCardNumber(request.Card.Number) {
+	case SimulatorCardDeclined:
+		return domain.PaymentDeclined, nil
+	case SimulatorCardInsufficientFunds:
+		return domain.PaymentInsufficientFunds, nil
+	case SimulatorCardTimeout:
+		return "", domain.ErrPaymentProcessorTimeout
+	default:
+		return domain.PaymentApproved, nil
+	}
+}
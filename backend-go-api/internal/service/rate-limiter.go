@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+type RateLimiterConfig struct {
+	// Store is where buckets live: memory for a single instance, postgres
+	// to share them between replicas.
+	Store   string
+	Default domain.RateLimit
+	// Routes overrides Default per "METHOD /route".
+	Routes map[string]domain.RateLimit
+	// Accounts overrides Routes and Default per account id, or per
+	// "<account id> METHOD /route".
+	Accounts map[string]domain.RateLimit
+}
+
+// NewRateLimiterConfig reads RATE_LIMIT_STORE, RATE_LIMIT_DEFAULT,
+// RATE_LIMIT_ROUTES and RATE_LIMIT_ACCOUNTS. Overrides are comma separated
+// "<scope>=<requests>/<period>" entries, e.g.
+// "POST /invoices=60/1m,GET /invoices/export=10/1h".
+func NewRateLimiterConfig() (*RateLimiterConfig, error) {
+	config := &RateLimiterConfig{
+		Store:   strings.ToLower(strings.TrimSpace(os.Getenv("RATE_LIMIT_STORE"))),
+		Default: domain.RateLimit{Requests: 300, Period: time.Minute},
+	}
+
+	switch config.Store {
+	case "":
+		config.Store = RateLimitStoreMemory
+	case RateLimitStoreMemory, RateLimitStorePostgres:
+	default:
+		return nil, domain.ErrUnsupportedRateLimitStore
+	}
+
+	if value := os.Getenv("RATE_LIMIT_DEFAULT"); value != "" {
+		limit, err := domain.ParseRateLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		config.Default = limit
+	}
+
+	var err error
+	if config.Routes, err = parseRateLimitRules(os.Getenv("RATE_LIMIT_ROUTES")); err != nil {
+		return nil, err
+	}
+	if config.Accounts, err = parseRateLimitRules(os.Getenv("RATE_LIMIT_ACCOUNTS")); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func parseRateLimitRules(value string) (map[string]domain.RateLimit, error) {
+	rules := make(map[string]domain.RateLimit)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		scope, rawLimit, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, domain.ErrInvalidRateLimit
+		}
+
+		limit, err := domain.ParseRateLimit(rawLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		rules[normalizeRateLimitScope(scope)] = limit
+	}
+
+	return rules, nil
+}
+
+// normalizeRateLimitScope turns "[account] [METHOD /route]" into the form
+// RateLimiter looks up.
+func normalizeRateLimitScope(scope string) string {
+	fields := strings.Fields(scope)
+	switch len(fields) {
+	case 2:
+		return rateLimitRoute(fields[0], fields[1])
+	case 3:
+		return fields[0] + " " + rateLimitRoute(fields[1], fields[2])
+	default:
+		return strings.TrimSpace(scope)
+	}
+}
+
+// rateLimitRoute names a route as "METHOD /pattern", ignoring the trailing
+// slash chi keeps on the root of sub-routers.
+func rateLimitRoute(method, pattern string) string {
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+
+	return strings.ToUpper(method) + " " + pattern
+}
+
+// RateLimiter gives every account one token bucket per route.
+type RateLimiter struct {
+	store  domain.RateLimitStore
+	config *RateLimiterConfig
+}
+
+func NewRateLimiter(store domain.RateLimitStore, config *RateLimiterConfig) *RateLimiter {
+	return &RateLimiter{store: store, config: config}
+}
+
+// Take spends a token of the bucket of the account on the route pattern.
+func (l *RateLimiter) Take(ctx context.Context, accountID, method, pattern string) (domain.RateLimitResult, error) {
+	route := rateLimitRoute(method, pattern)
+
+	return l.store.Take(ctx, accountID+" "+route, l.limitFor(accountID, route), time.Now())
+}
+
+func (l *RateLimiter) limitFor(accountID, route string) domain.RateLimit {
+	if limit, ok := l.config.Accounts[accountID+" "+route]; ok {
+		return limit
+	}
+	if limit, ok := l.config.Accounts[accountID]; ok {
+		return limit
+	}
+	if limit, ok := l.config.Routes[route]; ok {
+		return limit
+	}
+
+	return l.config.Default
+}
+
+// memoryRateLimitSweepInterval is how often full buckets are dropped from
+// memory. A full bucket holds no state worth keeping.
+const memoryRateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore keeps buckets in the process. Limits are enforced
+// per instance, so it only fits deployments with a single replica.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]time.Time)}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= memoryRateLimitSweepInterval {
+		for bucket, tat := range s.buckets {
+			if !tat.After(now) {
+				delete(s.buckets, bucket)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	tat, result := limit.Take(s.buckets[key], now)
+	s.buckets[key] = tat
+
+	return result, nil
+}
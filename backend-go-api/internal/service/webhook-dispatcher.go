@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// maxWebhookResponseBody bounds how much of an endpoint response is kept
+// with each attempt.
+const maxWebhookResponseBody = 1024
+
+// Headers sent with every webhook request.
+const (
+	WebhookSignatureHeader = "X-Gateway-Signature"
+	WebhookEventHeader     = "X-Gateway-Event"
+	WebhookDeliveryHeader  = "X-Gateway-Delivery"
+)
+
+var errWebhookEndpointDisabled = errors.New("webhook endpoint is disabled")
+
+type WebhookDispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	// Lease is how long claimed deliveries are reserved for the dispatcher
+	// that claimed them. It must cover sending the whole batch.
+	Lease time.Duration
+	// URLPolicy restricts the addresses webhooks are sent to.
+	URLPolicy domain.WebhookURLPolicy
+}
+
+func NewWebhookDispatcherConfig() *WebhookDispatcherConfig {
+	config := &WebhookDispatcherConfig{
+		PollInterval: envDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+		BatchSize:    envInt("WEBHOOK_BATCH_SIZE", 20),
+		MaxAttempts:  envInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseBackoff:  envDuration("WEBHOOK_BASE_BACKOFF", 10*time.Second),
+		MaxBackoff:   envDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+		Timeout:      envDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		URLPolicy:    newWebhookURLPolicy(),
+	}
+
+	// Deliveries of a batch are sent one after the other
+	config.Lease = envDuration("WEBHOOK_LEASE", time.Duration(config.BatchSize+1)*config.Timeout)
+
+	return config
+}
+
+// WebhookDispatcher posts queued webhook deliveries to merchant endpoints.
+// Any 2xx response counts as delivered; everything else is retried with
+// exponential backoff until the attempts run out.
+type WebhookDispatcher struct {
+	unitOfWork domain.UnitOfWork
+	client     *http.Client
+	config     *WebhookDispatcherConfig
+}
+
+func NewWebhookDispatcher(unitOfWork domain.UnitOfWork, config *WebhookDispatcherConfig) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		unitOfWork: unitOfWork,
+		client:     newWebhookClient(config),
+		config:     config,
+	}
+}
+
+// Run dispatches deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// The current batch is finished even if shutdown was requested
+		if _, err := d.DispatchBatch(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("erro ao enviar webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchBatch claims one batch of due deliveries and sends them. Requests
+// are made outside of any transaction, and the outcome of each delivery is
+// recorded in a short transaction of its own, so a failure to record one
+// outcome does not undo the others. A delivery whose outcome could not be
+// recorded is sent again when its lease ends. It returns how many
+// deliveries were delivered.
+func (d *WebhookDispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	var deliveries []*domain.WebhookDelivery
+
+	now := time.Now()
+	err := d.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		var err error
+		deliveries, err = repos.Webhooks.ClaimDueDeliveries(ctx, now, d.config.BatchSize, now.Add(d.config.Lease))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	var errs []error
+	for _, delivery := range deliveries {
+		ok, err := d.dispatch(ctx, delivery)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delivery %s: %w", delivery.ID, err))
+			continue
+		}
+
+		if ok {
+			delivered++
+		}
+	}
+
+	return delivered, errors.Join(errs...)
+}
+
+// dispatch sends a claimed delivery to its endpoint and records the
+// outcome. It reports whether the delivery was delivered.
+func (d *WebhookDispatcher) dispatch(ctx context.Context, delivery *domain.WebhookDelivery) (bool, error) {
+	var endpoint *domain.WebhookEndpoint
+
+	err := d.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		var err error
+		endpoint, err = repos.Webhooks.FindEndpoint(ctx, delivery.AccountID, delivery.EndpointID)
+		if err != nil || endpoint.Active {
+			return err
+		}
+
+		delivery.MarkFailed(errWebhookEndpointDisabled, time.Now(), true)
+		return repos.Webhooks.UpdateDelivery(ctx, delivery)
+	})
+	if err != nil || !endpoint.Active {
+		return false, err
+	}
+
+	attempt := d.send(ctx, endpoint, delivery)
+	if attempt.Error == "" {
+		delivery.MarkDelivered()
+	} else {
+		giveUp := delivery.Attempts+1 >= d.config.MaxAttempts
+		retryAt := time.Now().Add(exponentialBackoff(d.config.BaseBackoff, d.config.MaxBackoff, delivery.Attempts))
+		delivery.MarkFailed(errors.New(attempt.Error), retryAt, giveUp)
+
+		slog.Error("erro ao entregar webhook",
+			"error", attempt.Error,
+			"delivery_id", delivery.ID,
+			"endpoint_id", endpoint.ID,
+			"event_type", delivery.EventType,
+			"attempts", delivery.Attempts,
+			"give_up", giveUp)
+	}
+
+	err = d.unitOfWork.Do(ctx, func(ctx context.Context, repos *domain.Repositories) error {
+		if err := repos.Webhooks.AddAttempt(ctx, attempt); err != nil {
+			return err
+		}
+
+		return repos.Webhooks.UpdateDelivery(ctx, delivery)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return attempt.Error == "", nil
+}
+
+// newWebhookClient returns a client that only connects to addresses allowed
+// by the URL policy. The check runs on every connection, after DNS
+// resolution, so a host that starts resolving to an internal address is
+// refused. Redirects are not followed, and proxies from the environment are
+// not used since they would hide the real destination.
+func newWebhookClient(config *WebhookDispatcherConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return config.URLPolicy.CheckDial(address)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   config.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// send posts the delivery payload and records the outcome as an attempt.
+func (d *WebhookDispatcher) send(ctx context.Context, endpoint *domain.WebhookEndpoint, delivery *domain.WebhookDelivery) *domain.WebhookDeliveryAttempt {
+	now := time.Now()
+	attempt := domain.NewWebhookDeliveryAttempt(delivery.ID, now)
+	defer func() { attempt.Duration = time.Since(now) }()
+
+	// Endpoints registered under an older policy are checked again
+	if err := d.config.URLPolicy.ValidateURL(endpoint.URL); err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	request.Header.Set(WebhookSignatureHeader, "t="+timestamp+",v1="+endpoint.Sign(now, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxWebhookResponseBody))
+	attempt.ResponseStatus = response.StatusCode
+	attempt.ResponseBody = string(body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint responded with status %d", response.StatusCode)
+	}
+
+	return attempt
+}
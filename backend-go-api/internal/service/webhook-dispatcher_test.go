@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type webhookDispatcherTest struct {
+	dispatcher *WebhookDispatcher
+	webhooks   *fakeWebhookRepository
+	requests   atomic.Int32
+}
+
+// newWebhookDispatcherTest starts a merchant server that answers with the
+// status given in the path of the endpoint URL, such as /200.
+func newWebhookDispatcherTest(t *testing.T) (*webhookDispatcherTest, string) {
+	t.Helper()
+
+	test := &webhookDispatcherTest{webhooks: newFakeWebhookRepository()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		test.requests.Add(1)
+		if r.Header.Get(WebhookSignatureHeader) == "" || r.Header.Get(WebhookDeliveryHeader) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch strings.TrimPrefix(r.URL.Path, "/") {
+		case "200":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	test.dispatcher = NewWebhookDispatcher(&fakeUnitOfWork{repos: &domain.Repositories{Webhooks: test.webhooks}}, &WebhookDispatcherConfig{
+		BatchSize:   10,
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Minute,
+		Timeout:     time.Second,
+		Lease:       time.Minute,
+		URLPolicy:   domain.WebhookURLPolicy{AllowInsecure: true},
+	})
+
+	return test, server.URL
+}
+
+func (test *webhookDispatcherTest) queue(t *testing.T, url string) *domain.WebhookDelivery {
+	t.Helper()
+
+	endpoint, err := domain.NewWebhookEndpoint("account-1", url, []domain.WebhookEventType{domain.WebhookEventInvoiceCreated}, test.dispatcher.config.URLPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.webhooks.endpoints[endpoint.ID] = endpoint
+
+	delivery := domain.NewWebhookDelivery(endpoint, domain.WebhookEventInvoiceCreated, []byte(`{}`))
+	test.webhooks.CreateDelivery(context.Background(), delivery)
+
+	return delivery
+}
+
+func TestWebhookDispatcherRecordsEachDeliveryOnItsOwn(t *testing.T) {
+	test, serverURL := newWebhookDispatcherTest(t)
+	succeeded := test.queue(t, serverURL+"/200")
+	failed := test.queue(t, serverURL+"/500")
+	unrecorded := test.queue(t, serverURL+"/200")
+	test.webhooks.failAttempts[unrecorded.ID] = true
+
+	before := time.Now()
+	delivered, err := test.dispatcher.DispatchBatch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), unrecorded.ID) {
+		t.Fatalf("error = %v, want the delivery whose outcome was not recorded", err)
+	}
+	if delivered != 1 {
+		t.Errorf("delivered = %d, want 1", delivered)
+	}
+	if requests := test.requests.Load(); requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+
+	if got := test.webhooks.delivery(succeeded.ID); got.Status != domain.WebhookDeliverySucceeded || got.Attempts != 1 {
+		t.Errorf("succeeded delivery = %s after %d attempts, want succeeded after 1", got.Status, got.Attempts)
+	}
+
+	got := test.webhooks.delivery(failed.ID)
+	if got.Status != domain.WebhookDeliveryPending || got.Attempts != 1 || got.LastError == "" {
+		t.Errorf("failed delivery = %+v, want pending with 1 failed attempt", got)
+	}
+	if delay := got.NextAttemptAt.Sub(before); delay < time.Second || delay > 2*time.Second {
+		t.Errorf("failed delivery retried in %v, want about 1s", delay)
+	}
+
+	// The delivery keeps its lease and is sent again once it ends
+	got = test.webhooks.delivery(unrecorded.ID)
+	if got.Status != domain.WebhookDeliveryPending || got.Attempts != 0 {
+		t.Errorf("unrecorded delivery = %s after %d attempts, want pending with none", got.Status, got.Attempts)
+	}
+	if lease := got.NextAttemptAt.Sub(before); lease < time.Minute {
+		t.Errorf("unrecorded delivery due in %v, want after the 1m lease", lease)
+	}
+	if len(test.webhooks.attempts) != 2 {
+		t.Errorf("recorded %d attempts, want 2", len(test.webhooks.attempts))
+	}
+}
+
+func TestWebhookDispatcherSkipsLeasedDeliveries(t *testing.T) {
+	test, serverURL := newWebhookDispatcherTest(t)
+	delivery := test.queue(t, serverURL+"/200")
+
+	claimed, err := test.webhooks.ClaimDueDeliveries(context.Background(), time.Now(), 10, time.Now().Add(time.Minute))
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claimed %d deliveries, err = %v, want 1", len(claimed), err)
+	}
+
+	if delivered, err := test.dispatcher.DispatchBatch(context.Background()); err != nil || delivered != 0 {
+		t.Fatalf("delivered = %d, err = %v, want nothing while leased", delivered, err)
+	}
+	if requests := test.requests.Load(); requests != 0 {
+		t.Errorf("requests = %d, want none", requests)
+	}
+	if got := test.webhooks.delivery(delivery.ID); got.Status != domain.WebhookDeliveryPending {
+		t.Errorf("status = %s, want pending", got.Status)
+	}
+}
+
+func TestWebhookDispatcherFailsDeliveriesOfDisabledEndpoints(t *testing.T) {
+	test, serverURL := newWebhookDispatcherTest(t)
+	delivery := test.queue(t, serverURL+"/200")
+	test.webhooks.endpoints[delivery.EndpointID].Disable()
+
+	if delivered, err := test.dispatcher.DispatchBatch(context.Background()); err != nil || delivered != 0 {
+		t.Fatalf("delivered = %d, err = %v, want nothing", delivered, err)
+	}
+	if requests := test.requests.Load(); requests != 0 {
+		t.Errorf("requests = %d, want none", requests)
+	}
+	if got := test.webhooks.delivery(delivery.ID); got.Status != domain.WebhookDeliveryFailed {
+		t.Errorf("status = %s, want failed", got.Status)
+	}
+}
+
+func TestWebhookDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	test, serverURL := newWebhookDispatcherTest(t)
+	delivery := test.queue(t, serverURL+"/500")
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		stored := test.webhooks.delivery(delivery.ID)
+		stored.NextAttemptAt = time.Now()
+		test.webhooks.UpdateDelivery(context.Background(), &stored)
+
+		if _, err := test.dispatcher.DispatchBatch(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := test.webhooks.delivery(delivery.ID); got.Status != domain.WebhookDeliveryFailed || got.Attempts != 3 {
+		t.Errorf("delivery = %s after %d attempts, want failed after 3", got.Status, got.Attempts)
+	}
+}
+
+func TestWebhookDispatcherRefusesNonPublicAddresses(t *testing.T) {
+	test, serverURL := newWebhookDispatcherTest(t)
+	delivery := test.queue(t, serverURL+"/200")
+	test.dispatcher = NewWebhookDispatcher(test.dispatcher.unitOfWork, &WebhookDispatcherConfig{
+		BatchSize:   10,
+		MaxAttempts: 3,
+		Timeout:     time.Second,
+		Lease:       time.Minute,
+	})
+
+	if delivered, err := test.dispatcher.DispatchBatch(context.Background()); err != nil || delivered != 0 {
+		t.Fatalf("delivered = %d, err = %v, want nothing", delivered, err)
+	}
+	if requests := test.requests.Load(); requests != 0 {
+		t.Errorf("requests = %d, want none", requests)
+	}
+	if got := test.webhooks.delivery(delivery.ID); got.Attempts != 1 || got.LastError == "" {
+		t.Errorf("delivery = %+v, want a failed attempt", got)
+	}
+
+	// Connections are checked after DNS resolution, whatever the URL says
+	_, err := test.dispatcher.client.Post(serverURL+"/200", "application/json", nil)
+	if !errors.Is(err, domain.ErrWebhookAddressBlocked) {
+		t.Errorf("post to loopback error = %v, want ErrWebhookAddressBlocked", err)
+	}
+	if requests := test.requests.Load(); requests != 0 {
+		t.Errorf("requests = %d, want none", requests)
+	}
+}
+
+func TestWebhookDispatcherDoesNotFollowRedirects(t *testing.T) {
+	test, serverURL := newWebhookDispatcherTest(t)
+	redirect := httptest.NewServer(http.RedirectHandler(serverURL+"/200", http.StatusFound))
+	t.Cleanup(redirect.Close)
+	delivery := test.queue(t, redirect.URL)
+
+	if delivered, err := test.dispatcher.DispatchBatch(context.Background()); err != nil || delivered != 0 {
+		t.Fatalf("delivered = %d, err = %v, want nothing", delivered, err)
+	}
+	if requests := test.requests.Load(); requests != 0 {
+		t.Errorf("requests = %d, want the redirect not to be followed", requests)
+	}
+	if got := test.webhooks.delivery(delivery.ID); got.Status != domain.WebhookDeliveryPending || got.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want pending after 1 failed attempt", got.Status, got.Attempts)
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/google/uuid"
+)
+
+type WebhookServiceConfig struct {
+	// URLPolicy restricts the URLs endpoints may be registered with.
+	URLPolicy domain.WebhookURLPolicy
+}
+
+func NewWebhookServiceConfig() *WebhookServiceConfig {
+	return &WebhookServiceConfig{
+		URLPolicy: newWebhookURLPolicy(),
+	}
+}
+
+// newWebhookURLPolicy reads WEBHOOK_ALLOW_INSECURE_URLS, which lets local
+// setups deliver webhooks over http to private addresses.
+func newWebhookURLPolicy() domain.WebhookURLPolicy {
+	return domain.WebhookURLPolicy{
+		AllowInsecure: envBool("WEBHOOK_ALLOW_INSECURE_URLS", false),
+	}
+}
+
+type WebhookService struct {
+	repository domain.WebhookRepository
+	config     *WebhookServiceConfig
+}
+
+func NewWebhookService(repository domain.WebhookRepository, config *WebhookServiceConfig) *WebhookService {
+	return &WebhookService{
+		repository: repository,
+		config:     config,
+	}
+}
+
+// CreateEndpoint registers a webhook endpoint. The response carries the
+// signing secret, which is not returned again.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, input dto.CreateWebhookEndpointInput) (_ *dto.WebhookEndpointResponse, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateEndpoint")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := domain.NewWebhookEndpoint(principal.AccountID, input.URL, dto.ToWebhookEventTypes(input.EventTypes), s.config.URLPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	response := dto.FromWebhookEndpoint(endpoint)
+	response.Secret = endpoint.Secret
+
+	return &response, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context) (_ []dto.WebhookEndpointResponse, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListEndpoints")
+	defer func() { endSpan(span, err) }()
+
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := s.repository.FindEndpointsByAccountID(ctx, principal.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.WebhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, dto.FromWebhookEndpoint(endpoint))
+	}
+
+	return response, nil
+}
+
+// DisableEndpoint stops deliveries to the endpoint. Its delivery history is
+// kept.
+func (s *WebhookService) DisableEndpoint(ctx context.Context, endpointID string) (err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.DisableEndpoint")
+	defer func() { endSpan(span, err) }()
+
+	endpoint, err := s.findEndpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+
+	endpoint.Disable()
+	return s.repository.UpdateEndpoint(ctx, endpoint)
+}
+
+// ListDeliveries lists the deliveries of an endpoint newest first.
+func (s *WebhookService) ListDeliveries(ctx context.Context, endpointID, cursor string, limit int) (_ *dto.Page[dto.WebhookDeliveryResponse], err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	endpoint, err := s.findEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := dto.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = domain.ClampPageLimit(limit)
+
+	// Fetch one extra delivery to know whether there is a next page
+	deliveries, err := s.repository.FindDeliveries(ctx, endpoint.ID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dto.Page[dto.WebhookDeliveryResponse]{Data: make([]dto.WebhookDeliveryResponse, 0, len(deliveries))}
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		last := deliveries[len(deliveries)-1]
+		page.NextCursor = dto.EncodeCursor(domain.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	for _, delivery := range deliveries {
+		page.Data = append(page.Data, dto.FromWebhookDelivery(delivery))
+	}
+
+	return page, nil
+}
+
+// GetDelivery returns a delivery with its payload and every attempt made.
+func (s *WebhookService) GetDelivery(ctx context.Context, endpointID, deliveryID string) (_ *dto.WebhookDeliveryResponse, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetDelivery")
+	defer func() { endSpan(span, err) }()
+
+	endpoint, err := s.findEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repository.FindDelivery(ctx, endpoint.ID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.repository.FindAttempts(ctx, delivery.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := dto.FromWebhookDelivery(delivery)
+	response.Payload = delivery.Payload
+	response.AttemptLog = make([]dto.WebhookDeliveryAttemptResponse, 0, len(attempts))
+	for _, attempt := range attempts {
+		response.AttemptLog = append(response.AttemptLog, dto.FromWebhookDeliveryAttempt(attempt))
+	}
+
+	return &response, nil
+}
+
+// Redeliver schedules a delivery to be sent again, whatever its status. A
+// delivery a dispatcher is sending yields ErrWebhookDeliveryInFlight.
+func (s *WebhookService) Redeliver(ctx context.Context, endpointID, deliveryID string) (_ *dto.WebhookDeliveryResponse, err error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Redeliver")
+	defer func() { endSpan(span, err) }()
+
+	endpoint, err := s.findEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repository.FindDelivery(ctx, endpoint.ID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Redeliver()
+	redelivered, err := s.repository.RedeliverDelivery(ctx, delivery, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !redelivered {
+		return nil, domain.ErrWebhookDeliveryInFlight
+	}
+
+	response := dto.FromWebhookDelivery(delivery)
+	return &response, nil
+}
+
+func (s *WebhookService) findEndpoint(ctx context.Context, endpointID string) (*domain.WebhookEndpoint, error) {
+	principal, err := domain.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.FindEndpoint(ctx, principal.AccountID, endpointID)
+}
+
+// enqueueInvoiceWebhook queues an invoice event for every endpoint of the
+// invoice account subscribed to it. It must run in the transaction that
+// changed the invoice so events are only sent for committed changes.
+func enqueueInvoiceWebhook(ctx context.Context, repos *domain.Repositories, eventType domain.WebhookEventType, invoice *domain.Invoice) error {
+	endpoints, err := repos.Webhooks.FindSubscribedEndpoints(ctx, invoice.AccountID, eventType)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(dto.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      string(eventType),
+		CreatedAt: time.Now(),
+		Data:      dto.FromInvoice(invoice),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if err := repos.Webhooks.CreateDelivery(ctx, domain.NewWebhookDelivery(endpoint, eventType, payload)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueueInvoiceStatusWebhook queues the event matching a final invoice
+// status, if any.
+func enqueueInvoiceStatusWebhook(ctx context.Context, repos *domain.Repositories, invoice *domain.Invoice) error {
+	switch invoice.Status {
+	case domain.StatusApproved:
+		return enqueueInvoiceWebhook(ctx, repos, domain.WebhookEventInvoiceApproved, invoice)
+	case domain.StatusRejected:
+		return enqueueInvoiceWebhook(ctx, repos, domain.WebhookEventInvoiceRejected, invoice)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+// Package tracing configures the OpenTelemetry tracer provider.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Span exporters selected with OTEL_TRACES_EXPORTER.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+type Config struct {
+	Exporter    string
+	ServiceName string
+}
+
+// NewConfig reads OTEL_TRACES_EXPORTER (otlp, stdout or none, the default)
+// and OTEL_SERVICE_NAME. The OTLP exporter reads its endpoint from the
+// standard OTEL_EXPORTER_OTLP_* variables.
+func NewConfig() *Config {
+	config := &Config{
+		Exporter:    os.Getenv("OTEL_TRACES_EXPORTER"),
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+	}
+
+	if config.Exporter == "" {
+		config.Exporter = ExporterNone
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "go-gateway"
+	}
+
+	return config
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The returned func flushes buffered spans and must be called
+// on shutdown.
+func Setup(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.Exporter {
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterNone:
+		// The default no-op provider still propagates incoming trace context
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", config.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -4,11 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
-	"github.com/go-chi/chi/v5"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
 )
 
 type AccountHandler struct {
@@ -23,40 +24,105 @@ func (h *AccountHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input dto.CreateAccountInput
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.DecodeError(w, r, err)
 		return
 	}
 
-	response, err := h.accountService.CreateAccount(&input)
+	response, err := h.accountService.CreateAccount(r.Context(), &input)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *AccountHandler) Get(w http.ResponseWriter, r *http.Request) {
+	response, err := h.accountService.GetAccount(r.Context())
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrDuplicateAPIKey):
-			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrAccountNotFound):
+			problem.Error(w, r, http.StatusNotFound, err)
 		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *AccountHandler) Get(w http.ResponseWriter, r *http.Request) {
-	apiKey := r.Header.Get("X-API-KEY")
-	if apiKey == "" {
-		http.Error(w, "API-KEY is required", http.StatusUnauthorized)
+func (h *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var input dto.UpdateAccountInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.DecodeError(w, r, err)
+		return
+	}
+
+	response, err := h.accountService.UpdateAccount(r.Context(), input)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Close closes the account and anonymizes its personal data. The key used
+// for the request stops working as well.
+func (h *AccountHandler) Close(w http.ResponseWriter, r *http.Request) {
+	response, err := h.accountService.CloseAccount(r.Context())
+	if err != nil {
+		writeAccountError(w, r, err)
 		return
 	}
 
-	response, err := h.accountService.GetAccountByKey(apiKey)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeAccountError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		problem.Error(w, r, http.StatusUnauthorized, err)
+	case errors.Is(err, domain.ErrAccountNotFound):
+		problem.Error(w, r, http.StatusNotFound, err)
+	case errors.Is(err, domain.ErrInvalidInput):
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, domain.ErrDuplicateEmail), errors.Is(err, domain.ErrDuplicateAPIKey), errors.Is(err, domain.ErrAccountClosed), errors.Is(err, domain.ErrInvalidAccountStatus):
+		problem.Error(w, r, http.StatusConflict, err)
+	default:
+		problem.Error(w, r, http.StatusInternalServerError, err)
+	}
+}
+
+func (h *AccountHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			problem.Error(w, r, http.StatusBadRequest, domain.ErrInvalidPageLimit)
+			return
+		}
+		limit = parsed
+	}
+
+	response, err := h.accountService.GetLedger(r.Context(), r.URL.Query().Get("cursor"), limit)
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrAccountNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvalidCursor):
+			problem.Error(w, r, http.StatusBadRequest, err)
 		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
@@ -66,9 +132,21 @@ func (h *AccountHandler) Get(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *AccountHandler) Router() http.Handler {
-	router := chi.NewRouter()
-	router.Post("/", h.Create)
-	router.Get("/", h.Get)
-	return router
+func (h *AccountHandler) ReconcileBalances(w http.ResponseWriter, r *http.Request) {
+	response, err := h.accountService.ReconcileBalances(r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrAccountNotFound):
+			problem.Error(w, r, http.StatusNotFound, err)
+		default:
+			problem.Error(w, r, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
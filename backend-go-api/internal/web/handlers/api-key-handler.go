@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateAPIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem.DecodeError(w, r, err)
+		return
+	}
+
+	response, err := h.apiKeyService.CreateKey(r.Context(), input)
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	response, err := h.apiKeyService.ListKeys(r.Context())
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	var input dto.RevokeAPIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem.DecodeError(w, r, err)
+		return
+	}
+
+	response, err := h.apiKeyService.RevokeKey(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeAPIKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		problem.Error(w, r, http.StatusUnauthorized, err)
+	case errors.Is(err, domain.ErrAPIKeyNotFound):
+		problem.Error(w, r, http.StatusNotFound, err)
+	case errors.Is(err, domain.ErrAPIKeyRevoked), errors.Is(err, domain.ErrLastAPIKey), errors.Is(err, domain.ErrDuplicateAPIKey):
+		problem.Error(w, r, http.StatusConflict, err)
+	case errors.Is(err, domain.ErrInvalidInput):
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+	default:
+		problem.Error(w, r, http.StatusInternalServerError, err)
+	}
+}
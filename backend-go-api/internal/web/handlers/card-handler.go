@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
+)
+
+type CardHandler struct {
+	cardService *service.CardService
+}
+
+func NewCardHandler(cardService *service.CardService) *CardHandler {
+	return &CardHandler{cardService: cardService}
+}
+
+// CreateToken stores a card in the vault and returns its token
+func (h *CardHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateCardTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.DecodeError(w, r, err)
+		return
+	}
+
+	response, err := h.cardService.Tokenize(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvalidInput):
+			problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		default:
+			problem.Error(w, r, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
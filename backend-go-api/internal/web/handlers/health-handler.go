@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+)
+
+type HealthHandler struct {
+	healthService *service.HealthService
+}
+
+func NewHealthHandler(healthService *service.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService}
+}
+
+// Healthz reports that the process is up. It checks no dependency so the
+// orchestrator does not restart the gateway when Postgres or Kafka are down.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.HealthResponse{Status: dto.HealthStatusOK})
+}
+
+// Readyz reports whether the gateway can serve traffic, with the result of
+// each dependency check. It answers 503 when any check fails.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	response := h.healthService.Readiness(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != dto.HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
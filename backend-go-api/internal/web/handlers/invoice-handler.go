@@ -3,12 +3,17 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -24,112 +29,179 @@ func (h *InvoiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input dto.CreateInvoiceInput
 	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.DecodeError(w, r, err)
 		return
 	}
 
-	input.APIKey = r.Header.Get("X-API-KEY")
-	if input.APIKey == "" {
-		http.Error(w, "API-KEY is required", http.StatusUnauthorized)
-		return
-	}
+	input.IdempotencyKey = strings.TrimSpace(r.Header.Get("Idempotency-Key"))
 
-	response, err := h.invoiceService.CreateInvoice(input)
+	response, err := h.invoiceService.CreateInvoice(r.Context(), input)
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrAccountNotFound):
-			http.Error(w, "Internal server error during processing", http.StatusInternalServerError)
-		case errors.Is(err, domain.ErrInvalidAmount), errors.Is(err, domain.ErrInvalidStatus):
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvalidInput):
+			problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, domain.ErrInvalidStatus), errors.Is(err, domain.ErrInvalidIdempotencyKey):
+			problem.Error(w, r, http.StatusBadRequest, err)
+		case errors.Is(err, domain.ErrIdempotencyKeyMismatch):
+			problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, domain.ErrIdempotencyKeyInUse):
+			problem.Error(w, r, http.StatusConflict, err)
+		case errors.Is(err, domain.ErrPaymentProcessorTimeout):
+			problem.Error(w, r, http.StatusGatewayTimeout, err)
 		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
 
+	if response.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *InvoiceHandler) Get(w http.ResponseWriter, r *http.Request) {
-	apiKey := r.Header.Get("X-API-KEY")
-	if apiKey == "" {
-		http.Error(w, "API-KEY is required", http.StatusUnauthorized)
+func (h *InvoiceHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	response, err := h.invoiceService.GetInvoiceByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvoiceNotFound):
+			problem.Error(w, r, http.StatusNotFound, err)
+		case errors.Is(err, domain.ErrUnauthorizedAccess):
+			problem.Error(w, r, http.StatusForbidden, err)
+		default:
+			problem.Error(w, r, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *InvoiceHandler) Refund(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+
+	// The body is optional: an empty body refunds the remaining amount
+	var input dto.CreateRefundInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem.DecodeError(w, r, err)
 		return
 	}
 
-	response, err := h.invoiceService.GetInvoiceByID(id, apiKey)
+	response, err := h.invoiceService.RefundInvoice(r.Context(), id, input)
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrInvoiceNotFound), errors.Is(err, domain.ErrAccountNotFound):
-			http.Error(w, "Invoice not found or invalid API key", http.StatusNotFound)
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvoiceNotFound):
+			problem.Error(w, r, http.StatusNotFound, err)
 		case errors.Is(err, domain.ErrUnauthorizedAccess):
-			http.Error(w, "Forbidden: Invoice does not belong to this account", http.StatusForbidden)
+			problem.Error(w, r, http.StatusForbidden, err)
+		case errors.Is(err, domain.ErrInvalidInput):
+			problem.Error(w, r, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, domain.ErrInvoiceNotRefundable):
+			problem.Error(w, r, http.StatusConflict, err)
+		case errors.Is(err, domain.ErrRefundExceedsAmount), errors.Is(err, domain.ErrInsufficientBalance):
+			problem.Error(w, r, http.StatusUnprocessableEntity, err)
 		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *InvoiceHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+// Capture captures all or part of an authorized invoice
+func (h *InvoiceHandler) Capture(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	apiKey := r.Header.Get("X-API-KEY")
-	if apiKey == "" {
-		http.Error(w, "API-KEY is required", http.StatusUnauthorized)
+
+	// The body is optional: an empty body captures the full amount
+	var input dto.CaptureInvoiceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem.DecodeError(w, r, err)
 		return
 	}
 
-	response, err := h.invoiceService.GetInvoiceByID(id, apiKey)
+	response, err := h.invoiceService.CaptureInvoice(r.Context(), id, input)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrInvoiceNotFound), errors.Is(err, domain.ErrAccountNotFound):
-			http.Error(w, "Invoice not found or invalid API key", http.StatusNotFound)
-		case errors.Is(err, domain.ErrUnauthorizedAccess):
-			http.Error(w, "Forbidden: Invoice does not belong to this account", http.StatusForbidden)
-		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		writeAuthorizationError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Void cancels an authorized invoice before capture
+func (h *InvoiceHandler) Void(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	response, err := h.invoiceService.VoidInvoice(r.Context(), id)
+	if err != nil {
+		writeAuthorizationError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *InvoiceHandler) Router() http.Handler {
-	router := chi.NewRouter()
-	router.Post("/invoices", h.Create)
-	router.Get("/invoices", h.Get)
-	router.Get("/invoices/{id}", h.GetByID)
-	return router
+func writeAuthorizationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		problem.Error(w, r, http.StatusUnauthorized, err)
+	case errors.Is(err, domain.ErrInvoiceNotFound):
+		problem.Error(w, r, http.StatusNotFound, err)
+	case errors.Is(err, domain.ErrUnauthorizedAccess):
+		problem.Error(w, r, http.StatusForbidden, err)
+	case errors.Is(err, domain.ErrInvalidInput):
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, domain.ErrInvalidStatus), errors.Is(err, domain.ErrAuthorizationExpired):
+		problem.Error(w, r, http.StatusConflict, err)
+	case errors.Is(err, domain.ErrCaptureExceedsAmount):
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+	default:
+		problem.Error(w, r, http.StatusInternalServerError, err)
+	}
 }
 
 func (h *InvoiceHandler) ListByAccount(w http.ResponseWriter, r *http.Request) {
-	apiKey := r.Header.Get("X-API-KEY")
-	if isInvalidAPIKey(apiKey) {
-		http.Error(w, "Valid API-KEY is required", http.StatusUnauthorized)
+	filter, err := dto.ToInvoiceFilter(r.URL.Query())
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	response, err := h.invoiceService.ListByAccountAPIKey(apiKey)
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			problem.Error(w, r, http.StatusBadRequest, domain.ErrInvalidPageLimit)
+			return
+		}
+		limit = parsed
+	}
+
+	response, err := h.invoiceService.ListInvoices(r.Context(), filter, r.URL.Query().Get("cursor"), limit)
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrAccountNotFound):
-			http.Error(w, "Account not found for API key", http.StatusNotFound)
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvalidInvoiceFilter), errors.Is(err, domain.ErrInvalidCursor):
+			problem.Error(w, r, http.StatusBadRequest, err)
 		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
@@ -138,7 +210,46 @@ func (h *InvoiceHandler) ListByAccount(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func isInvalidAPIKey(key string) bool {
-	key = strings.TrimSpace(key)
-	return key == "" || key == "undefined" || key == "null"
+// Export streams every invoice matching the listing filters as CSV or JSON
+// Lines, without pagination.
+func (h *InvoiceHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := dto.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	filter, err := dto.ToInvoiceFilter(r.URL.Query())
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	exporter := dto.NewInvoiceExporter(format, w)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": dto.InvoiceExportFilename(filter, format, time.Now()),
+	}))
+
+	err = h.invoiceService.ExportInvoices(r.Context(), filter, exporter.Write)
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err != nil {
+		if exporter.Started() {
+			// The status is already sent; abort so the client sees a
+			// truncated response instead of a complete export
+			panic(http.ErrAbortHandler)
+		}
+
+		w.Header().Del("Content-Disposition")
+		switch {
+		case errors.Is(err, domain.ErrUnauthenticated):
+			problem.Error(w, r, http.StatusUnauthorized, err)
+		case errors.Is(err, domain.ErrInvalidInvoiceFilter):
+			problem.Error(w, r, http.StatusBadRequest, err)
+		default:
+			problem.Error(w, r, http.StatusInternalServerError, err)
+		}
+	}
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/dto"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateWebhookEndpointInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		problem.DecodeError(w, r, err)
+		return
+	}
+
+	response, err := h.webhookService.CreateEndpoint(r.Context(), input)
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	response, err := h.webhookService.ListEndpoints(r.Context())
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.webhookService.DisableEndpoint(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			problem.Error(w, r, http.StatusBadRequest, domain.ErrInvalidPageLimit)
+			return
+		}
+		limit = parsed
+	}
+
+	response, err := h.webhookService.ListDeliveries(r.Context(), chi.URLParam(r, "id"), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	response, err := h.webhookService.GetDelivery(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	response, err := h.webhookService.Redeliver(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeWebhookError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		problem.Error(w, r, http.StatusUnauthorized, err)
+	case errors.Is(err, domain.ErrWebhookEndpointNotFound), errors.Is(err, domain.ErrWebhookDeliveryNotFound):
+		problem.Error(w, r, http.StatusNotFound, err)
+	case errors.Is(err, domain.ErrWebhookDeliveryInFlight):
+		problem.Error(w, r, http.StatusConflict, err)
+	case errors.Is(err, domain.ErrInvalidInput):
+		problem.Error(w, r, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, domain.ErrInvalidCursor):
+		problem.Error(w, r, http.StatusBadRequest, err)
+	default:
+		problem.Error(w, r, http.StatusInternalServerError, err)
+	}
+}
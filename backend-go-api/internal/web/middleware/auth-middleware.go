@@ -6,35 +6,44 @@ import (
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
 )
 
 type AuthMiddleware struct {
-	accountService *service.AccountService
+	apiKeyService *service.APIKeyService
 }
 
-func NewAuthMiddleware(accountService *service.AccountService) *AuthMiddleware {
-	return &AuthMiddleware{accountService: accountService}
+func NewAuthMiddleware(apiKeyService *service.APIKeyService) *AuthMiddleware {
+	return &AuthMiddleware{apiKeyService: apiKeyService}
 }
 
+// Authenticate rejects requests without an active key from the api_keys
+// table. Revoked keys keep passing until their grace period ends, and keys
+// of suspended accounts are refused with 403 Forbidden. The
+// principal of the key is put in the request context, where handlers and
+// services read it with domain.PrincipalFromContext.
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		apiKey := strings.TrimSpace(r.Header.Get("X-API-KEY"))
 
 		if apiKey == "" {
-			http.Error(w, "API-KEY is required", http.StatusUnauthorized)
+			problem.Write(w, r, http.StatusUnauthorized, "missing_api_key", "API-KEY is required")
 			return
 		}
 
-		_, err := m.accountService.GetAccountByKey(apiKey)
+		principal, err := m.apiKeyService.Authenticate(r.Context(), apiKey)
 		if err != nil {
-			if err == domain.ErrAccountNotFound {
-				http.Error(w, "Account not found", http.StatusUnauthorized)
-				return
+			switch err {
+			case domain.ErrAccountNotFound:
+				problem.Error(w, r, http.StatusUnauthorized, err)
+			case domain.ErrAccountSuspended:
+				problem.Error(w, r, http.StatusForbidden, err)
+			default:
+				problem.Error(w, r, http.StatusInternalServerError, err)
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(domain.WithPrincipal(r.Context(), *principal)))
 	})
 }
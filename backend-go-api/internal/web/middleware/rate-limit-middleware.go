@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+type RateLimitMiddleware struct {
+	limiter *service.RateLimiter
+	routes  chi.Routes
+}
+
+// NewRateLimitMiddleware limits requests per account and route. routes is
+// the root router, used to resolve the full route pattern before the
+// sub-router has matched it.
+func NewRateLimitMiddleware(limiter *service.RateLimiter, routes chi.Routes) *RateLimitMiddleware {
+	return &RateLimitMiddleware{limiter: limiter, routes: routes}
+}
+
+// Limit must run after AuthMiddleware.Authenticate. It sets the RateLimit-*
+// headers on every response and answers 429 with Retry-After once the
+// bucket is empty. Requests go through when the store fails, so an outage
+// of the store does not take the API down.
+func (m *RateLimitMiddleware) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := domain.PrincipalFromContext(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := m.routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		result, err := m.limiter.Take(r.Context(), principal.AccountID, r.Method, route)
+		if err != nil {
+			slog.Error("falha ao aplicar o rate limit", "account_id", principal.AccountID, "route", route, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writeRateLimitHeaders(w, result)
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			problem.Error(w, r, http.StatusTooManyRequests, domain.ErrRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeRateLimitHeaders(w http.ResponseWriter, result domain.RateLimitResult) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit.Requests))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(result.Limit.Requests)+";w="+strconv.Itoa(ceilSeconds(result.Limit.Period)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,94 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+type registryEntry struct {
+	err   error
+	code  string
+	field string
+}
+
+// registry gives every domain error a client can run into its problem code,
+// and the request field it is about when there is one. Codes are part of
+// the API: add new ones, but do not rename them.
+var registry = []registryEntry{
+	{domain.ErrUnauthenticated, "unauthenticated", ""},
+	{domain.ErrUnauthorizedAccess, "forbidden", ""},
+	{domain.ErrRateLimited, "rate_limited", ""},
+
+	{domain.ErrAccountNotFound, "account_not_found", ""},
+	{domain.ErrAccountSuspended, "account_suspended", ""},
+	{domain.ErrAccountClosed, "account_closed", ""},
+	{domain.ErrInvalidAccountStatus, "invalid_account_status", ""},
+	{domain.ErrInvalidAccountName, "invalid_name", "name"},
+	{domain.ErrInvalidEmail, "invalid_email", "email"},
+	{domain.ErrDuplicateEmail, "duplicate_email", "email"},
+
+	{domain.ErrAPIKeyNotFound, "api_key_not_found", ""},
+	{domain.ErrAPIKeyRevoked, "api_key_revoked", ""},
+	{domain.ErrLastAPIKey, "last_api_key", ""},
+	{domain.ErrDuplicateAPIKey, "duplicate_api_key", ""},
+	{domain.ErrInvalidAPIKeyLabel, "invalid_label", "label"},
+	{domain.ErrInvalidGracePeriod, "invalid_grace_period", "grace_period"},
+
+	{domain.ErrInvoiceNotFound, "invoice_not_found", ""},
+	{domain.ErrInvalidAmount, "invalid_amount", "amount"},
+	{domain.ErrInvalidMoney, "invalid_amount", "amount"},
+	{domain.ErrMoneyNotString, "amount_not_string", "amount"},
+	{domain.ErrAmountTooLarge, "amount_too_large", "amount"},
+	{domain.ErrCurrencyMismatch, "currency_mismatch", "currency"},
+	{domain.ErrUnsupportedCurrency, "unsupported_currency", "currency"},
+	{domain.ErrInvalidStatus, "invalid_status", ""},
+	{domain.ErrInvalidInvoiceFilter, "invalid_filter", ""},
+	{domain.ErrInvalidCursor, "invalid_cursor", "cursor"},
+	{domain.ErrInvalidPageLimit, "invalid_limit", "limit"},
+	{domain.ErrUnsupportedExportFormat, "unsupported_export_format", "format"},
+	{domain.ErrInvoiceNotRefundable, "invoice_not_refundable", ""},
+	{domain.ErrRefundExceedsAmount, "refund_exceeds_amount", "amount"},
+	{domain.ErrInsufficientBalance, "insufficient_balance", ""},
+	{domain.ErrAuthorizationExpired, "authorization_expired", ""},
+	{domain.ErrCaptureExceedsAmount, "capture_exceeds_amount", "amount"},
+	{domain.ErrPaymentProcessorTimeout, "payment_processor_timeout", ""},
+
+	{domain.ErrInvalidCard, "invalid_card", ""},
+	{domain.ErrCardTokenNotFound, "card_token_not_found", "card_token"},
+
+	{domain.ErrInvalidWebhookURL, "invalid_webhook_url", "url"},
+	{domain.ErrInvalidWebhookEventType, "invalid_event_types", "event_types"},
+	{domain.ErrWebhookEndpointNotFound, "webhook_endpoint_not_found", ""},
+	{domain.ErrWebhookDeliveryNotFound, "webhook_delivery_not_found", ""},
+	{domain.ErrWebhookDeliveryInFlight, "webhook_delivery_in_flight", ""},
+
+	{domain.ErrInvalidIdempotencyKey, "invalid_idempotency_key", ""},
+	{domain.ErrIdempotencyKeyMismatch, "idempotency_key_mismatch", ""},
+	{domain.ErrIdempotencyKeyInUse, "idempotency_key_in_use", ""},
+
+	{domain.ErrInvalidInput, "validation_failed", ""},
+}
+
+func lookup(err error) registryEntry {
+	for _, entry := range registry {
+		if errors.Is(err, entry.err) {
+			return entry
+		}
+	}
+
+	return registryEntry{}
+}
+
+// statusCode is the code of problems without a more specific one, such as
+// "not_found" for 404 Not Found.
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -0,0 +1,124 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json).
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code, Message, Field,
+// RequestID and Errors are extension members: Code is a stable machine
+// readable identifier, Message repeats Detail, Field names the rejected
+// request field and Errors lists every rejected field.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Field     string       `json:"field,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the id the request ID middleware gave the request, or
+// an empty string.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Write answers with a problem of the given status, code and message.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	write(w, r, Problem{Status: status, Code: code, Detail: message})
+}
+
+// Error answers with a problem describing err. Codes and fields of domain
+// errors come from the registry in codes.go. Server errors hide err from
+// the client and log it along with the request id instead.
+func Error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		slog.Error("erro ao processar requisição",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"request_id", RequestID(r.Context()),
+			"error", err,
+		)
+		write(w, r, Problem{Status: status, Code: statusCode(status), Detail: http.StatusText(status)})
+		return
+	}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		p := Problem{Status: status, Code: "validation_failed", Detail: "request has invalid fields"}
+		for _, field := range validationErr.Fields {
+			p.Errors = append(p.Errors, FieldError{Field: field.Field, Code: field.Code, Message: field.Message})
+		}
+		if len(p.Errors) == 1 {
+			p.Field = p.Errors[0].Field
+			p.Detail = p.Errors[0].Field + " " + p.Errors[0].Message
+		}
+		write(w, r, p)
+		return
+	}
+
+	if cardErr, ok := domain.AsCardValidationError(err); ok {
+		write(w, r, Problem{Status: status, Code: "invalid_card", Detail: cardErr.Message, Field: cardErr.Field})
+		return
+	}
+
+	entry := lookup(err)
+	if entry.code == "" {
+		entry.code = statusCode(status)
+	}
+
+	write(w, r, Problem{Status: status, Code: entry.code, Detail: err.Error(), Field: entry.field})
+}
+
+// DecodeError answers 400 Bad Request for a body that could not be decoded.
+// Field errors raised by custom decoders, such as domain.ErrInvalidMoney,
+// are answered like any other field error instead: 422 Unprocessable
+// Entity with their code and field.
+func DecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, domain.ErrInvalidInput) {
+		Error(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	Write(w, r, http.StatusBadRequest, "malformed_body", err.Error())
+}
+
+func write(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Message = p.Detail
+	p.Instance = r.URL.Path
+	p.RequestID = RequestID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
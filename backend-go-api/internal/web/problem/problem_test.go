@@ -0,0 +1,155 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devfullcycle/imersao22/go-gateway/internal/domain"
+)
+
+// record answers a request to /invoices through answer and decodes the
+// problem it wrote.
+func record(t *testing.T, answer func(http.ResponseWriter, *http.Request)) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/invoices", nil)
+	request = request.WithContext(WithRequestID(request.Context(), "request-1"))
+	recorder := httptest.NewRecorder()
+	answer(recorder, request)
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != ContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, ContentType)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(recorder.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != recorder.Code || p.Instance != "/invoices" || p.RequestID != "request-1" || p.Message != p.Detail {
+		t.Errorf("problem = %+v, want status %d, the instance, the request id and the message", p, recorder.Code)
+	}
+
+	return recorder, p
+}
+
+func TestErrorValidationError(t *testing.T) {
+	err := &domain.ValidationError{Fields: []domain.FieldError{
+		{Field: "amount", Code: "required", Message: "is required"},
+		{Field: "currency", Code: "unsupported_currency", Message: "must be one of BRL, EUR, USD"},
+	}}
+
+	_, p := record(t, func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusUnprocessableEntity, err)
+	})
+
+	want := []FieldError{
+		{Field: "amount", Code: "required", Message: "is required"},
+		{Field: "currency", Code: "unsupported_currency", Message: "must be one of BRL, EUR, USD"},
+	}
+	if p.Code != "validation_failed" || p.Field != "" || len(p.Errors) != 2 || p.Errors[0] != want[0] || p.Errors[1] != want[1] {
+		t.Errorf("problem = %+v, want validation_failed listing %v", p, want)
+	}
+}
+
+func TestErrorSingleFieldValidationError(t *testing.T) {
+	err := &domain.ValidationError{Fields: []domain.FieldError{{Field: "name", Code: "blank", Message: "must not be blank"}}}
+
+	_, p := record(t, func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusUnprocessableEntity, err)
+	})
+
+	if p.Field != "name" || p.Detail != "name must not be blank" || len(p.Errors) != 1 {
+		t.Errorf("problem = %+v, want the name field in detail and errors", p)
+	}
+}
+
+func TestErrorDomainErrors(t *testing.T) {
+	tests := []struct {
+		err       error
+		wantCode  string
+		wantField string
+	}{
+		{domain.ErrInvalidAmount, "invalid_amount", "amount"},
+		{fmt.Errorf("refund: %w", domain.ErrUnsupportedCurrency), "unsupported_currency", "currency"},
+		{domain.ErrInvalidWebhookURL, "invalid_webhook_url", "url"},
+		{domain.ErrInvalidGracePeriod, "invalid_grace_period", "grace_period"},
+		{domain.ErrInvalidInput, "validation_failed", ""},
+		{domain.ErrInvoiceNotFound, "invoice_not_found", ""},
+		{errors.New("something else"), "unprocessable_entity", ""},
+	}
+
+	for _, tt := range tests {
+		_, p := record(t, func(w http.ResponseWriter, r *http.Request) {
+			Error(w, r, http.StatusUnprocessableEntity, tt.err)
+		})
+
+		if p.Code != tt.wantCode || p.Field != tt.wantField || p.Detail != tt.err.Error() {
+			t.Errorf("%v: problem = %+v, want code %s and field %q", tt.err, p, tt.wantCode, tt.wantField)
+		}
+	}
+}
+
+func TestErrorCardValidationError(t *testing.T) {
+	card := domain.CreditCard{Number: "4111111111111111", CVV: "1", ExpiryMonth: 12, ExpiryYear: 2099}
+	_, err := card.Validate(time.Now())
+	if err == nil {
+		t.Fatal("want a card validation error")
+	}
+
+	_, p := record(t, func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusUnprocessableEntity, err)
+	})
+
+	if p.Code != "invalid_card" || p.Field != domain.CardFieldCVV {
+		t.Errorf("problem = %+v, want invalid_card on %s", p, domain.CardFieldCVV)
+	}
+}
+
+func TestErrorHidesServerErrors(t *testing.T) {
+	_, p := record(t, func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusInternalServerError, errors.New("pq: connection refused"))
+	})
+
+	if p.Code != "internal_server_error" || p.Detail != "Internal Server Error" {
+		t.Errorf("problem = %+v, want the error hidden", p)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"json decimal number amount", `{"amount": 100.5}`, http.StatusUnprocessableEntity, "amount_not_string"},
+		{"amount too large", `{"amount": "100000000.00"}`, http.StatusUnprocessableEntity, "amount_too_large"},
+		{"too many decimals", `{"amount": "1.005"}`, http.StatusUnprocessableEntity, "invalid_amount"},
+		{"malformed json", `{"amount": `, http.StatusBadRequest, "malformed_body"},
+		{"wrong type", `{"description": 1}`, http.StatusBadRequest, "malformed_body"},
+	}
+
+	for _, tt := range tests {
+		var input struct {
+			Amount      domain.Money `json:"amount"`
+			Description string       `json:"description"`
+		}
+		err := json.Unmarshal([]byte(tt.body), &input)
+		if err == nil {
+			t.Fatalf("%s: decoding %s did not fail", tt.name, tt.body)
+		}
+
+		recorder, p := record(t, func(w http.ResponseWriter, r *http.Request) {
+			DecodeError(w, r, err)
+		})
+
+		if recorder.Code != tt.wantStatus || p.Code != tt.wantCode {
+			t.Errorf("%s: status %d code %s, want %d %s", tt.name, recorder.Code, p.Code, tt.wantStatus, tt.wantCode)
+		}
+	}
+}
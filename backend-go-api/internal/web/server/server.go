@@ -1,13 +1,16 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/devfullcycle/imersao22/go-gateway/internal/service"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/web/handlers"
 	"github.com/devfullcycle/imersao22/go-gateway/internal/web/middleware"
+	"github.com/devfullcycle/imersao22/go-gateway/internal/web/problem"
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
@@ -15,46 +18,118 @@ type Server struct {
 	server         *http.Server
 	accountService *service.AccountService
 	invoiceService *service.InvoiceService
+	cardService    *service.CardService
+	webhookService *service.WebhookService
+	apiKeyService  *service.APIKeyService
+	healthService  *service.HealthService
+	rateLimiter    *service.RateLimiter
 	port           string
 }
 
-func NewServer(accountService *service.AccountService, invoiceService *service.InvoiceService, port string) *Server {
+func NewServer(accountService *service.AccountService, invoiceService *service.InvoiceService, cardService *service.CardService, webhookService *service.WebhookService, apiKeyService *service.APIKeyService, healthService *service.HealthService, rateLimiter *service.RateLimiter, port string) *Server {
+	router := chi.NewRouter()
+
 	return &Server{
-		router:         chi.NewRouter(),
+		router: router,
+		server: &http.Server{
+			Addr:    fmt.Sprintf(":%s", port),
+			Handler: router,
+		},
 		accountService: accountService,
 		invoiceService: invoiceService,
+		cardService:    cardService,
+		webhookService: webhookService,
+		apiKeyService:  apiKeyService,
+		healthService:  healthService,
+		rateLimiter:    rateLimiter,
 		port:           port,
 	}
 }
 
+// Start serves requests until Shutdown is called, returning
+// http.ErrServerClosed in that case.
 func (s *Server) Start() error {
 	s.ConfigureRoutes()
 
-	s.server = &http.Server{
-		Addr:    fmt.Sprintf(":%s", s.port),
-		Handler: s.router,
-	}
-
 	println("Server started on port", s.port)
 
 	return s.server.ListenAndServe()
 }
 
+// Shutdown stops accepting connections and waits for in-flight requests
+// to finish or ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
 func (s *Server) ConfigureRoutes() {
 	accountHandler :=
 		handlers.NewAccountHandler(s.accountService)
 	invoiceHandler := handlers.NewInvoiceHandler(s.invoiceService)
-	authMiddleware := middleware.NewAuthMiddleware(s.accountService)
+	cardHandler := handlers.NewCardHandler(s.cardService)
+	webhookHandler := handlers.NewWebhookHandler(s.webhookService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(s.apiKeyService)
+	healthHandler := handlers.NewHealthHandler(s.healthService)
+	authMiddleware := middleware.NewAuthMiddleware(s.apiKeyService)
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(s.rateLimiter, s.router)
+
+	s.router.Use(middleware.Metrics)
+	s.router.Use(middleware.Tracing)
+	s.router.Use(middleware.RequestID)
+
+	s.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, http.StatusNotFound, "route_not_found", "no route matches "+r.URL.Path)
+	})
+	s.router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed on "+r.URL.Path)
+	})
+
+	s.router.Handle("/metrics", promhttp.Handler())
+	s.router.Get("/healthz", healthHandler.Healthz)
+	s.router.Get("/readyz", healthHandler.Readyz)
 
 	s.router.Route("/accounts", func(r chi.Router) {
 		r.Post("/", accountHandler.Create)
-		r.Get("/", accountHandler.Get)
+
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware.Authenticate, rateLimitMiddleware.Limit)
+			r.Get("/", accountHandler.Get)
+			r.Patch("/", accountHandler.Update)
+			r.Delete("/", accountHandler.Close)
+			r.Get("/ledger", accountHandler.GetLedger)
+			r.Get("/ledger/reconciliation", accountHandler.ReconcileBalances)
+
+			r.Route("/api-keys", func(r chi.Router) {
+				r.Post("/", apiKeyHandler.Create)
+				r.Get("/", apiKeyHandler.List)
+				r.Post("/{id}/revoke", apiKeyHandler.Revoke)
+			})
+		})
 	})
 
 	s.router.Route("/invoices", func(r chi.Router) {
-		r.Use(authMiddleware.Authenticate)
+		r.Use(authMiddleware.Authenticate, rateLimitMiddleware.Limit)
 		r.Post("/", invoiceHandler.Create)
 		r.Get("/", invoiceHandler.ListByAccount)
+		r.Get("/export", invoiceHandler.Export)
 		r.Get("/{id}", invoiceHandler.GetByID)
+		r.Post("/{id}/refunds", invoiceHandler.Refund)
+		r.Post("/{id}/capture", invoiceHandler.Capture)
+		r.Post("/{id}/void", invoiceHandler.Void)
+	})
+
+	s.router.Route("/cards", func(r chi.Router) {
+		r.Use(authMiddleware.Authenticate, rateLimitMiddleware.Limit)
+		r.Post("/tokens", cardHandler.CreateToken)
+	})
+
+	s.router.Route("/webhooks", func(r chi.Router) {
+		r.Use(authMiddleware.Authenticate, rateLimitMiddleware.Limit)
+		r.Post("/", webhookHandler.Create)
+		r.Get("/", webhookHandler.List)
+		r.Delete("/{id}", webhookHandler.Delete)
+		r.Get("/{id}/deliveries", webhookHandler.ListDeliveries)
+		r.Get("/{id}/deliveries/{deliveryID}", webhookHandler.GetDelivery)
+		r.Post("/{id}/deliveries/{deliveryID}/redeliver", webhookHandler.Redeliver)
 	})
 }
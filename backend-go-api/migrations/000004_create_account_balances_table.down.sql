@@ -0,0 +1,10 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS balance DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+UPDATE accounts a
+SET balance = b.balance
+FROM account_balances b
+WHERE b.account_id = a.id AND b.currency = 'BRL';
+
+DROP TABLE IF EXISTS account_balances;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS currency;
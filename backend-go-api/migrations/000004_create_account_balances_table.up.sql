@@ -0,0 +1,16 @@
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'BRL';
+
+CREATE TABLE IF NOT EXISTS account_balances (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    currency VARCHAR(3) NOT NULL,
+    balance DECIMAL(10,2) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, currency)
+);
+
+-- Existing balances were all kept in BRL
+INSERT INTO account_balances (account_id, currency, balance, updated_at)
+SELECT id, 'BRL', balance, updated_at FROM accounts
+ON CONFLICT (account_id, currency) DO NOTHING;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS balance;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL,
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    ledger_account VARCHAR(50) NOT NULL,
+    direction VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    entry_type VARCHAR(50) NOT NULL,
+    reference_id UUID,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_created ON ledger_entries(account_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference_id ON ledger_entries(reference_id);
+
+-- Postings are append-only
+CREATE OR REPLACE FUNCTION prevent_ledger_entry_changes() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger_entries are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_immutable
+BEFORE UPDATE OR DELETE ON ledger_entries
+FOR EACH ROW EXECUTE FUNCTION prevent_ledger_entry_changes();
+
+-- Open the ledger with the balances accumulated before it existed, so that
+-- cached balances reconcile from day one
+WITH opening AS (
+    SELECT account_id, currency, balance, gen_random_uuid() AS transaction_id
+    FROM account_balances
+    WHERE balance <> 0
+)
+INSERT INTO ledger_entries (transaction_id, account_id, ledger_account, direction, amount, currency, entry_type, description)
+SELECT transaction_id, account_id, 'merchant_balance',
+       CASE WHEN balance > 0 THEN 'credit' ELSE 'debit' END,
+       ABS(balance), currency, 'opening_balance', 'opening balance'
+FROM opening
+UNION ALL
+SELECT transaction_id, account_id, 'opening_balance',
+       CASE WHEN balance > 0 THEN 'debit' ELSE 'credit' END,
+       ABS(balance), currency, 'opening_balance', 'opening balance'
+FROM opening;
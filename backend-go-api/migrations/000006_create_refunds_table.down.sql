@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS refunded_amount;
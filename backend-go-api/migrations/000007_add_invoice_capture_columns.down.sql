@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_invoices_authorization_expires_at;
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS authorization_expires_at;
+ALTER TABLE invoices DROP COLUMN IF EXISTS captured_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS auto_capture;
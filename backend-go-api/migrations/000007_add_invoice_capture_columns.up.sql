@@ -0,0 +1,10 @@
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS auto_capture BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS captured_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS authorization_expires_at TIMESTAMP;
+
+-- Invoices approved before two-step payments existed were captured in full
+UPDATE invoices
+SET captured_amount = amount
+WHERE status IN ('approved', 'partially_refunded', 'refunded');
+
+CREATE INDEX IF NOT EXISTS idx_invoices_authorization_expires_at ON invoices(authorization_expires_at) WHERE status = 'authorized';
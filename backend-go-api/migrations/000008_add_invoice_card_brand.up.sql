@@ -0,0 +1 @@
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS card_brand VARCHAR(20) NOT NULL DEFAULT '';
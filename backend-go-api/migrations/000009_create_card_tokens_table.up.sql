@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS card_tokens (
+    id VARCHAR(64) PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    encrypted_number BYTEA NOT NULL,
+    brand VARCHAR(20) NOT NULL,
+    last_digits VARCHAR(4) NOT NULL,
+    expiry_month INTEGER NOT NULL,
+    expiry_year INTEGER NOT NULL,
+    cardholder_name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_tokens_account_id ON card_tokens(account_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_invoices_account_amount;
+DROP INDEX IF EXISTS idx_invoices_account_created_at;
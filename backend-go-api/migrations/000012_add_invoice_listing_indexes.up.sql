@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_invoices_account_created_at ON invoices (account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_invoices_account_amount ON invoices (account_id, amount, id);
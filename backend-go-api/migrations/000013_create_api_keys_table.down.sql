@@ -0,0 +1,19 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS api_key VARCHAR(255);
+
+-- Keep the oldest key that was never revoked, or the oldest one otherwise
+UPDATE accounts
+SET api_key = (
+    SELECT k.api_key
+    FROM api_keys k
+    WHERE k.account_id = accounts.id
+    ORDER BY k.revoked_at IS NOT NULL, k.created_at
+    LIMIT 1
+);
+
+UPDATE accounts SET api_key = md5(random()::text || id::text) WHERE api_key IS NULL;
+
+ALTER TABLE accounts ALTER COLUMN api_key SET NOT NULL;
+ALTER TABLE accounts ADD CONSTRAINT accounts_api_key_key UNIQUE (api_key);
+CREATE INDEX IF NOT EXISTS idx_accounts_api_key ON accounts(api_key);
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    api_key VARCHAR(255) NOT NULL UNIQUE,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_account_id ON api_keys(account_id);
+
+-- The key every account was created with becomes its first api key
+INSERT INTO api_keys (account_id, api_key, label, created_at)
+SELECT id, api_key, 'default', created_at
+FROM accounts
+ON CONFLICT (api_key) DO NOTHING;
+
+DROP INDEX IF EXISTS idx_accounts_api_key;
+ALTER TABLE accounts DROP COLUMN IF EXISTS api_key;
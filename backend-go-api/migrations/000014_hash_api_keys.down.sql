@@ -0,0 +1,12 @@
+-- Plaintext keys cannot be recovered from their digests. Rolling back gives
+-- every key a random value, so keys have to be issued again afterwards.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS api_key VARCHAR(255);
+
+UPDATE api_keys SET api_key = md5(random()::text || id::text) WHERE api_key IS NULL;
+
+ALTER TABLE api_keys ALTER COLUMN api_key SET NOT NULL;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_api_key_key UNIQUE (api_key);
+
+DROP INDEX IF EXISTS idx_api_keys_key_prefix;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS key_hash;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS key_prefix;
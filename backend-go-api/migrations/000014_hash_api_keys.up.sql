@@ -0,0 +1,15 @@
+-- Keys are random 128-bit values, so an unsalted SHA-256 digest is enough
+-- to keep them secret. Existing keys are hashed in place and keep working.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_prefix VARCHAR(16);
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_hash BYTEA;
+
+UPDATE api_keys
+SET key_prefix = LEFT(api_key, 8), key_hash = sha256(convert_to(api_key, 'UTF8'))
+WHERE key_hash IS NULL;
+
+ALTER TABLE api_keys ALTER COLUMN key_prefix SET NOT NULL;
+ALTER TABLE api_keys ALTER COLUMN key_hash SET NOT NULL;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_key_prefix ON api_keys(key_prefix);
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS api_key;
@@ -0,0 +1,3 @@
+-- Closed accounts stay anonymized; their personal data cannot be restored
+ALTER TABLE accounts DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_rate_limits_tat;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_rate_limits_tat ON rate_limits (tat);
@@ -0,0 +1,8 @@
+// Package migrations embeds the golang-migrate files of the database
+// schema, so that the build knows which version it expects.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var Files embed.FS
@@ -25,12 +25,12 @@ export async function createInvoiceAction(formData: FormData) {
       "X-API-Key": apiKey as string,
     },
     body: JSON.stringify({
-      amount: parseFloat(amount as string),
+      amount: amount,
       description,
       card_number: cardNumber,
       expiry_month: parseInt(expiryMonth as string),
       expiry_year: parseInt(expiryYear as string),
-      cvv,
+      card_cvv: cvv,
       cardholder_name: cardholderName,
       payment_type: "credit_card",
     }),
@@ -18,7 +18,8 @@ export async function getInvoices() {
       tags: [`accounts/${apiKey}/invoices`]
     }
   });
-  return response.json();
+  const page = await response.json();
+  return page.data;
 }
 
 export async function InvoiceList() {
@@ -129,7 +130,7 @@ export async function InvoiceList() {
                 </td>
                 <td className="py-4 px-4 text-white">{invoice.description}</td>
                 <td className="py-4 px-4 text-white">
-                  R$ {invoice.amount.toFixed(2).replace(".", ",")}
+                  R$ {invoice.amount.replace(".", ",")}
                 </td>
                 <td className="py-4 px-4">
                   <StatusBadge status={invoice.status} />